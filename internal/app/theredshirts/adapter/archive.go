@@ -0,0 +1,127 @@
+package adapter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+)
+
+const archive_day_format = "2006-01-02"
+
+type (
+	MessageArchive interface {
+		Archive(messages []*ArchivedMessage) error
+	}
+
+	ArchivedMessage struct {
+		ID       uuid.UUID              `json:"id"`
+		SendTime time.Time              `json:"send_time"`
+		LobbyId  uuid.UUID              `json:"lobby_id"`
+		PlayerId uuid.UUID              `json:"player_id"`
+		Number   int                    `json:"number"`
+		Topic    string                 `json:"topic"`
+		Message  map[string]interface{} `json:"message"`
+		Direct   bool                   `json:"direct"`
+		Channel  string                 `json:"channel"`
+		Team     string                 `json:"team"`
+		Revision int                    `json:"revision"`
+		// Revisions are the replaced contents of an edited message, the oldest first
+		Revisions []*ArchivedRevision `json:"revisions,omitempty"`
+	}
+
+	ArchivedRevision struct {
+		Revision int                    `json:"revision"`
+		Number   int                    `json:"number"`
+		Message  map[string]interface{} `json:"message"`
+	}
+
+	// FileArchive writes messages as gzip compressed newline delimited json to <directory>/<lobby id>/<day>/<id of first message>.ndjson.gz.
+	// Archiving the same messages again replaces their file, so messages archived again after their deletion failed are not duplicated.
+	FileArchive struct {
+		directory string
+	}
+
+	archivePartition struct {
+		lobbyId uuid.UUID
+		day     string
+	}
+)
+
+// NewMessageArchive returns nil if no archive directory is configured.
+func NewMessageArchive() MessageArchive {
+	directory := util.GetEnvWithFallback("ARCHIVE_DIRECTORY", "")
+	if directory == "" {
+		return nil
+	}
+	return NewFileArchive(directory)
+}
+
+func NewFileArchive(directory string) *FileArchive {
+	return &FileArchive{directory: directory}
+}
+
+// Archive returns after all files are synced to disk. If writing one partition fails, the files already written by this call are removed again.
+func (archive *FileArchive) Archive(messages []*ArchivedMessage) error {
+	partitions := make(map[archivePartition][]*ArchivedMessage)
+	for _, message := range messages {
+		partition := archivePartition{lobbyId: message.LobbyId, day: message.SendTime.UTC().Format(archive_day_format)}
+		partitions[partition] = append(partitions[partition], message)
+	}
+
+	var written []string
+	for partition, partitionMessages := range partitions {
+		path := filepath.Join(archive.directory, partition.lobbyId.String(), partition.day, partitionMessages[0].ID.String()+".ndjson.gz")
+		if err := writeArchiveFile(path, partitionMessages); err != nil {
+			for _, writtenPath := range written {
+				os.Remove(writtenPath)
+			}
+			return fmt.Errorf("error while archiving messages of lobby %v from %s: %v", partition.lobbyId, partition.day, err)
+		}
+		written = append(written, path)
+	}
+	return nil
+}
+
+func writeArchiveFile(path string, messages []*ArchivedMessage) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error while creating directory: %v", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return fmt.Errorf("error while creating file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	buffer := bufio.NewWriter(file)
+	compressor := gzip.NewWriter(buffer)
+	encoder := json.NewEncoder(compressor)
+	for _, message := range messages {
+		if err := encoder.Encode(message); err != nil {
+			return fmt.Errorf("error while writing message %v: %v", message.ID, err)
+		}
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("error while compressing messages: %v", err)
+	}
+	if err := buffer.Flush(); err != nil {
+		return fmt.Errorf("error while writing file: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("error while syncing file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error while closing file: %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("error while renaming file: %v", err)
+	}
+	return nil
+}
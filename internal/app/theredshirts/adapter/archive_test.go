@@ -0,0 +1,82 @@
+package adapter
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func readArchiveFiles(t *testing.T, directory string) []*ArchivedMessage {
+	files, err := filepath.Glob(filepath.Join(directory, "*.ndjson.gz"))
+	assert.Nil(t, err)
+	var messages []*ArchivedMessage
+	for _, path := range files {
+		file, err := os.Open(path)
+		assert.Nil(t, err)
+		reader, err := gzip.NewReader(file)
+		assert.Nil(t, err)
+		decoder := json.NewDecoder(reader)
+		for decoder.More() {
+			var message ArchivedMessage
+			assert.Nil(t, decoder.Decode(&message))
+			messages = append(messages, &message)
+		}
+		file.Close()
+	}
+	return messages
+}
+
+func TestFileArchiveArchive_PartitionedByLobbyAndDay(t *testing.T) {
+	directory := t.TempDir()
+	someLobbyId := uuid.New()
+	otherLobbyId := uuid.New()
+	someDay := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	otherDay := someDay.Add(24 * time.Hour)
+	messages := []*ArchivedMessage{
+		{ID: uuid.New(), SendTime: someDay, LobbyId: someLobbyId, Number: 1, Topic: "CHAT"},
+		{ID: uuid.New(), SendTime: someDay, LobbyId: someLobbyId, Number: 2, Topic: "CHAT"},
+		{ID: uuid.New(), SendTime: otherDay, LobbyId: someLobbyId, Number: 3, Topic: "CHAT"},
+		{ID: uuid.New(), SendTime: someDay, LobbyId: otherLobbyId, Number: 1, Topic: "CHAT"},
+	}
+
+	err := NewFileArchive(directory).Archive(messages)
+	assert.Nil(t, err)
+
+	someLobbySomeDay := readArchiveFiles(t, filepath.Join(directory, someLobbyId.String(), "2023-03-01"))
+	assert.Len(t, someLobbySomeDay, 2)
+	assert.Equal(t, messages[0].ID, someLobbySomeDay[0].ID)
+	assert.Equal(t, messages[1].ID, someLobbySomeDay[1].ID)
+	assert.Len(t, readArchiveFiles(t, filepath.Join(directory, someLobbyId.String(), "2023-03-02")), 1)
+	assert.Len(t, readArchiveFiles(t, filepath.Join(directory, otherLobbyId.String(), "2023-03-01")), 1)
+}
+
+func TestFileArchiveArchive_NewFilePerCall(t *testing.T) {
+	directory := t.TempDir()
+	someLobbyId := uuid.New()
+	someDay := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	archive := NewFileArchive(directory)
+
+	assert.Nil(t, archive.Archive([]*ArchivedMessage{{ID: uuid.New(), SendTime: someDay, LobbyId: someLobbyId}}))
+	assert.Nil(t, archive.Archive([]*ArchivedMessage{{ID: uuid.New(), SendTime: someDay, LobbyId: someLobbyId}}))
+
+	assert.Len(t, readArchiveFiles(t, filepath.Join(directory, someLobbyId.String(), "2023-03-01")), 2)
+}
+
+func TestFileArchiveArchive_SameMessagesReplaced(t *testing.T) {
+	directory := t.TempDir()
+	someLobbyId := uuid.New()
+	someDay := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	archive := NewFileArchive(directory)
+	messages := []*ArchivedMessage{{ID: uuid.New(), SendTime: someDay, LobbyId: someLobbyId, Number: 1}, {ID: uuid.New(), SendTime: someDay, LobbyId: someLobbyId, Number: 2}}
+
+	assert.Nil(t, archive.Archive(messages))
+	assert.Nil(t, archive.Archive(messages))
+
+	assert.Len(t, readArchiveFiles(t, filepath.Join(directory, someLobbyId.String(), "2023-03-01")), 2)
+}
@@ -0,0 +1,106 @@
+package adapter
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	breaker_closed breakerState = iota
+	breaker_half_open
+	breaker_open
+)
+
+var (
+	ErrLobbyServiceUnavailable = errors.New("lobby service unavailable")
+
+	breakerStateGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "message",
+		Name:      "lobby_circuit_breaker_state",
+		Help:      "State of the circuit breaker in front of the lobby service, 0 is closed, 1 is half open and 2 is open.",
+	})
+	breakerRejectedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "message",
+		Name:      "lobby_circuit_breaker_rejected_total",
+		Help:      "Number of requests to the lobby service rejected by the open circuit breaker.",
+	})
+)
+
+type (
+	breakerState int
+
+	// circuitBreaker opens after a number of failed requests in a row and rejects requests until the open duration passed.
+	// Afterwards a single request is let through, it closes the breaker again if it succeeds.
+	circuitBreaker struct {
+		mutex        sync.Mutex
+		state        breakerState
+		failures     int
+		threshold    int
+		openDuration time.Duration
+		openedAt     time.Time
+		trialRunning bool
+	}
+)
+
+func newCircuitBreaker(threshold int, openDuration time.Duration) *circuitBreaker {
+	breakerStateGauge.Set(float64(breaker_closed))
+	return &circuitBreaker{state: breaker_closed, threshold: threshold, openDuration: openDuration}
+}
+
+func (breaker *circuitBreaker) allow() error {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	switch breaker.state {
+	case breaker_open:
+		if time.Since(breaker.openedAt) < breaker.openDuration {
+			breakerRejectedCounter.Inc()
+			return ErrLobbyServiceUnavailable
+		}
+		breaker.setState(breaker_half_open)
+		breaker.trialRunning = true
+		return nil
+	case breaker_half_open:
+		if breaker.trialRunning {
+			breakerRejectedCounter.Inc()
+			return ErrLobbyServiceUnavailable
+		}
+		breaker.trialRunning = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (breaker *circuitBreaker) success() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	breaker.failures = 0
+	breaker.trialRunning = false
+	breaker.setState(breaker_closed)
+}
+
+func (breaker *circuitBreaker) failure() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	breaker.failures++
+	breaker.trialRunning = false
+	if breaker.state == breaker_half_open || breaker.failures >= breaker.threshold {
+		breaker.openedAt = time.Now()
+		breaker.setState(breaker_open)
+	}
+}
+
+func (breaker *circuitBreaker) open() bool {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	return breaker.state == breaker_open && time.Since(breaker.openedAt) < breaker.openDuration
+}
+
+func (breaker *circuitBreaker) setState(state breakerState) {
+	breaker.state = state
+	breakerStateGauge.Set(float64(state))
+}
@@ -0,0 +1,52 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	breaker := newCircuitBreaker(2, time.Minute)
+
+	assert.Nil(t, breaker.allow())
+	breaker.failure()
+	assert.Nil(t, breaker.allow())
+	breaker.failure()
+
+	assert.ErrorIs(t, breaker.allow(), ErrLobbyServiceUnavailable)
+	assert.True(t, breaker.open())
+}
+
+func TestCircuitBreaker_SuccessResetsFailures(t *testing.T) {
+	breaker := newCircuitBreaker(2, time.Minute)
+
+	breaker.failure()
+	breaker.success()
+	breaker.failure()
+
+	assert.Nil(t, breaker.allow())
+}
+
+func TestCircuitBreaker_HalfOpenAfterDuration(t *testing.T) {
+	breaker := newCircuitBreaker(1, time.Millisecond)
+	breaker.failure()
+	time.Sleep(2 * time.Millisecond)
+
+	assert.Nil(t, breaker.allow())
+	assert.ErrorIs(t, breaker.allow(), ErrLobbyServiceUnavailable)
+	breaker.success()
+	assert.Nil(t, breaker.allow())
+}
+
+func TestCircuitBreaker_ReopensWhenTrialFails(t *testing.T) {
+	breaker := newCircuitBreaker(1, time.Millisecond)
+	breaker.failure()
+	time.Sleep(2 * time.Millisecond)
+
+	assert.Nil(t, breaker.allow())
+	breaker.failure()
+
+	assert.True(t, breaker.open())
+}
@@ -0,0 +1,110 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+)
+
+type (
+	// PlayerCache keeps players of a directory for a while. Unknown players are cached as well, so clients of removed players do not reach the directory on every request.
+	PlayerCache struct {
+		directory   PlayerDirectory
+		ttl         time.Duration
+		negativeTtl time.Duration
+		mutex       sync.Mutex
+		entries     map[uuid.UUID]*playerCacheEntry
+		lastPrune   time.Time
+	}
+
+	playerCacheEntry struct {
+		player  *SimplePlayer
+		expires time.Time
+	}
+)
+
+func NewPlayerCache(directory PlayerDirectory) (*PlayerCache, error) {
+	ttl, err := util.GetEnvIntWithFallback("PLAYER_CACHE_TTL", 10)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading player cache ttl from environment variable: %v", err)
+	}
+	negativeTtl, err := util.GetEnvIntWithFallback("PLAYER_CACHE_NEGATIVE_TTL", 5)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading player cache negative ttl from environment variable: %v", err)
+	}
+	return &PlayerCache{directory: directory, ttl: time.Duration(ttl) * time.Second, negativeTtl: time.Duration(negativeTtl) * time.Second, entries: make(map[uuid.UUID]*playerCacheEntry), lastPrune: time.Now()}, nil
+}
+
+func (cache *PlayerCache) GetPlayer(context *util.Context, playerId uuid.UUID) (*SimplePlayer, error) {
+	if entry, ok := cache.get(playerId); ok {
+		if entry.player == nil {
+			return nil, ErrPlayerNotFound
+		}
+		player := *entry.player
+		return &player, nil
+	}
+
+	player, err := cache.directory.GetPlayer(context, playerId)
+	if err != nil {
+		if errors.Is(err, ErrPlayerNotFound) {
+			cache.put(playerId, nil, cache.negativeTtl)
+		}
+		return nil, err
+	}
+	cachedPlayer := *player
+	cache.put(playerId, &cachedPlayer, cache.ttl)
+	return player, nil
+}
+
+func (cache *PlayerCache) UpdatePlayerLastRefresh(context *util.Context, playerId uuid.UUID) error {
+	return cache.directory.UpdatePlayerLastRefresh(context, playerId)
+}
+
+func (cache *PlayerCache) Ping(context *util.Context) error {
+	return cache.directory.Ping(context)
+}
+
+// InvalidatePlayer removes the player from the cache, the next request loads the player from the directory again.
+func (cache *PlayerCache) InvalidatePlayer(playerId uuid.UUID) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	delete(cache.entries, playerId)
+}
+
+func (cache *PlayerCache) get(playerId uuid.UUID) (*playerCacheEntry, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, ok := cache.entries[playerId]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(cache.entries, playerId)
+		return nil, false
+	}
+	return entry, true
+}
+
+func (cache *PlayerCache) put(playerId uuid.UUID, player *SimplePlayer, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	now := time.Now()
+	cache.entries[playerId] = &playerCacheEntry{player: player, expires: now.Add(ttl)}
+
+	if now.Sub(cache.lastPrune) < cache.ttl {
+		return
+	}
+	for id, entry := range cache.entries {
+		if now.After(entry.expires) {
+			delete(cache.entries, id)
+		}
+	}
+	cache.lastPrune = now
+}
@@ -0,0 +1,75 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestContext() *util.Context {
+	return &util.Context{CorrelationId: "test", Logger: log.WithFields(log.Fields{})}
+}
+
+func TestPlayerCacheGetPlayer_Cached(t *testing.T) {
+	somePlayer := &SimplePlayer{ID: uuid.New(), LobbyId: uuid.New()}
+	directory := NewStaticPlayerDirectory(somePlayer)
+	cache, err := NewPlayerCache(directory)
+	assert.Nil(t, err)
+
+	_, err = cache.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.Nil(t, err)
+	directory.RemovePlayer(somePlayer.ID)
+
+	player, err := cache.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, somePlayer.LobbyId, player.LobbyId)
+}
+
+func TestPlayerCacheGetPlayer_NotFoundCached(t *testing.T) {
+	somePlayer := &SimplePlayer{ID: uuid.New(), LobbyId: uuid.New()}
+	directory := NewStaticPlayerDirectory()
+	cache, err := NewPlayerCache(directory)
+	assert.Nil(t, err)
+
+	_, err = cache.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.ErrorIs(t, err, ErrPlayerNotFound)
+	directory.PutPlayer(somePlayer)
+
+	_, err = cache.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.ErrorIs(t, err, ErrPlayerNotFound)
+}
+
+func TestPlayerCacheInvalidatePlayer_Reloaded(t *testing.T) {
+	somePlayer := &SimplePlayer{ID: uuid.New(), LobbyId: uuid.New()}
+	otherLobbyId := uuid.New()
+	directory := NewStaticPlayerDirectory(somePlayer)
+	cache, err := NewPlayerCache(directory)
+	assert.Nil(t, err)
+
+	_, err = cache.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.Nil(t, err)
+	directory.PutPlayer(&SimplePlayer{ID: somePlayer.ID, LobbyId: otherLobbyId})
+	cache.InvalidatePlayer(somePlayer.ID)
+
+	player, err := cache.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, otherLobbyId, player.LobbyId)
+}
+
+func TestPlayerCacheGetPlayer_Disabled(t *testing.T) {
+	t.Setenv("PLAYER_CACHE_TTL", "0")
+	somePlayer := &SimplePlayer{ID: uuid.New(), LobbyId: uuid.New()}
+	directory := NewStaticPlayerDirectory(somePlayer)
+	cache, err := NewPlayerCache(directory)
+	assert.Nil(t, err)
+
+	_, err = cache.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.Nil(t, err)
+	directory.RemovePlayer(somePlayer.ID)
+
+	_, err = cache.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.ErrorIs(t, err, ErrPlayerNotFound)
+}
@@ -0,0 +1,33 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+)
+
+type (
+	PlayerDirectory interface {
+		GetPlayer(context *util.Context, playerId uuid.UUID) (*SimplePlayer, error)
+		UpdatePlayerLastRefresh(context *util.Context, playerId uuid.UUID) error
+		Ping(context *util.Context) error
+	}
+)
+
+var (
+	ErrPlayerNotFound = errors.New("player not found")
+)
+
+func NewPlayerDirectory() (PlayerDirectory, error) {
+	switch directory := strings.ToLower(util.GetEnvWithFallback("PLAYER_DIRECTORY", "lobby")); directory {
+	case "lobby":
+		return NewLobbyAdapter()
+	case "static":
+		return newStaticPlayerDirectoryFromFile(util.GetEnvWithFallback("STATIC_PLAYERS_FILE", ""))
+	default:
+		return nil, fmt.Errorf("no player directory %s found", directory)
+	}
+}
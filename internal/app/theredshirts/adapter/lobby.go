@@ -3,7 +3,10 @@ package adapter
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
+	"time"
 
 	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
 	"github.com/google/uuid"
@@ -11,12 +14,19 @@ import (
 
 type (
 	LobbyAdapter struct {
-		ServerUrl string
+		ServerUrl    string
+		client       *http.Client
+		retries      int
+		retryBackoff time.Duration
+		breaker      *circuitBreaker
+		// refreshBreaker is separate from breaker, so a failing refresh endpoint never blocks loading players
+		refreshBreaker *circuitBreaker
 	}
 	SimplePlayer struct {
 		ID      uuid.UUID `json:"id" `
 		Name    string    `json:"name" `
 		LobbyId uuid.UUID `json:"lobby_id"`
+		Team    string    `json:"team"`
 	}
 )
 
@@ -28,17 +38,55 @@ const (
 	content_typ               = "Content-Type"
 )
 
-func NewLobbyAdapter() *LobbyAdapter {
+func NewLobbyAdapter() (*LobbyAdapter, error) {
 	serverUrl := util.GetEnvWithFallback("CHAT_SERVER_URL", "http://theredshirts-lobby:1203")
-	return &LobbyAdapter{ServerUrl: serverUrl}
+	timeout, err := util.GetEnvIntWithFallback("LOBBY_TIMEOUT_MS", 2000)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading lobby timeout from environment variable: %v", err)
+	}
+	retries, err := util.GetEnvIntWithFallback("LOBBY_RETRIES", 2)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading lobby retries from environment variable: %v", err)
+	}
+	retryBackoff, err := util.GetEnvIntWithFallback("LOBBY_RETRY_BACKOFF_MS", 100)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading lobby retry backoff from environment variable: %v", err)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("lobby timeout has to be positive but was %d", timeout)
+	}
+	if retries < 0 {
+		return nil, fmt.Errorf("lobby retries must not be negative but was %d", retries)
+	}
+	if retryBackoff <= 0 {
+		return nil, fmt.Errorf("lobby retry backoff has to be positive but was %d", retryBackoff)
+	}
+	breakerThreshold, err := util.GetEnvIntWithFallback("LOBBY_BREAKER_THRESHOLD", 5)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading lobby circuit breaker threshold from environment variable: %v", err)
+	}
+	breakerOpenDuration, err := util.GetEnvIntWithFallback("LOBBY_BREAKER_OPEN_DURATION", 10)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading lobby circuit breaker open duration from environment variable: %v", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 32
+	client := &http.Client{Timeout: time.Duration(timeout) * time.Millisecond, Transport: transport}
+	breaker := newCircuitBreaker(breakerThreshold, time.Duration(breakerOpenDuration)*time.Second)
+	refreshBreaker := newCircuitBreaker(breakerThreshold, time.Duration(breakerOpenDuration)*time.Second)
+	return &LobbyAdapter{ServerUrl: serverUrl, client: client, retries: retries, retryBackoff: time.Duration(retryBackoff) * time.Millisecond, breaker: breaker, refreshBreaker: refreshBreaker}, nil
 }
 
 func (adapter *LobbyAdapter) GetPlayer(context *util.Context, playerId uuid.UUID) (*SimplePlayer, error) {
 	response, err := adapter.sendGetPlayer(context, playerId)
 	if err != nil {
-		return nil, fmt.Errorf("error while getting player: %v", err)
+		return nil, fmt.Errorf("error while getting player: %w", err)
 	}
 	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrPlayerNotFound
+	}
 	if response.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("wrong status of response while getting player: %v", response.StatusCode)
 	}
@@ -54,7 +102,7 @@ func (adapter *LobbyAdapter) GetPlayer(context *util.Context, playerId uuid.UUID
 func (adapter *LobbyAdapter) UpdatePlayerLastRefresh(context *util.Context, playerId uuid.UUID) error {
 	response, err := adapter.sendUpdatePlayerLastRefresh(context, playerId)
 	if err != nil {
-		return fmt.Errorf("error while updating player: %v", err)
+		return fmt.Errorf("error while updating player: %w", err)
 	}
 	defer response.Body.Close()
 	if response.StatusCode != http.StatusOK {
@@ -64,9 +112,29 @@ func (adapter *LobbyAdapter) UpdatePlayerLastRefresh(context *util.Context, play
 	return nil
 }
 
-func (adapter *LobbyAdapter) sendGetPlayer(context *util.Context, playerId uuid.UUID) (*http.Response, error) {
-	client := &http.Client{}
+// Ping checks if the lobby service answers. It neither retries nor counts towards the circuit breaker, but fails while the breaker is open.
+func (adapter *LobbyAdapter) Ping(context *util.Context) error {
+	if adapter.breaker.open() {
+		return fmt.Errorf("%w: circuit breaker is open", ErrLobbyServiceUnavailable)
+	}
+	req, err := http.NewRequest(http.MethodGet, adapter.ServerUrl, nil)
+	if err != nil {
+		return fmt.Errorf("request to ping lobby service could not be build: %v", err)
+	}
+	req.Header.Set(correlation_id, context.CorrelationId)
+	resp, err := adapter.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLobbyServiceUnavailable, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: server error with status %v", ErrLobbyServiceUnavailable, resp.StatusCode)
+	}
+	return nil
+}
 
+func (adapter *LobbyAdapter) sendGetPlayer(context *util.Context, playerId uuid.UUID) (*http.Response, error) {
 	path := fmt.Sprintf(lobby_get_player_path, adapter.ServerUrl, playerId)
 	req, err := http.NewRequest(http.MethodGet, path, nil)
 	if err != nil {
@@ -76,17 +144,15 @@ func (adapter *LobbyAdapter) sendGetPlayer(context *util.Context, playerId uuid.
 	req.Header.Set(correlation_id, context.CorrelationId)
 	req.Header.Set("uber-trace-id", context.CorrelationId)
 	req.Header.Set(content_typ, content_typ_value)
-	resp, err := client.Do(req)
+	resp, err := adapter.send(context, adapter.breaker, req, adapter.retries)
 
 	if err != nil {
-		return nil, fmt.Errorf("request to get player not possible: %v", err)
+		return nil, fmt.Errorf("request to get player not possible: %w", err)
 	}
 	return resp, nil
 }
 
 func (adapter *LobbyAdapter) sendUpdatePlayerLastRefresh(context *util.Context, playerId uuid.UUID) (*http.Response, error) {
-	client := &http.Client{}
-
 	path := fmt.Sprintf(lobby_refresh_player_path, adapter.ServerUrl, playerId)
 	req, err := http.NewRequest(http.MethodPatch, path, nil)
 	if err != nil {
@@ -95,10 +161,37 @@ func (adapter *LobbyAdapter) sendUpdatePlayerLastRefresh(context *util.Context,
 
 	req.Header.Set(correlation_id, context.CorrelationId)
 	req.Header.Set(content_typ, content_typ_value)
-	resp, err := client.Do(req)
+	resp, err := adapter.send(context, adapter.refreshBreaker, req, 0)
 
 	if err != nil {
-		return nil, fmt.Errorf("request to update last refesh for player not possible: %v", err)
+		return nil, fmt.Errorf("request to update last refesh for player not possible: %w", err)
 	}
 	return resp, nil
 }
+
+// send executes the request through the given circuit breaker. Requests failing because of the connection or a server error are retried,
+// only pass retries for idempotent requests without body.
+func (adapter *LobbyAdapter) send(context *util.Context, breaker *circuitBreaker, req *http.Request, retries int) (*http.Response, error) {
+	if err := breaker.allow(); err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := adapter.client.Do(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			breaker.success()
+			return resp, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("server error with status %v", resp.StatusCode)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if attempt >= retries {
+			breaker.failure()
+			return nil, fmt.Errorf("%w: %v", ErrLobbyServiceUnavailable, err)
+		}
+		backoff := adapter.retryBackoff << attempt
+		context.Logger.Debugf("Request to lobby service failed, retrying in %v: %v", backoff, err)
+		time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff)+1)))
+	}
+}
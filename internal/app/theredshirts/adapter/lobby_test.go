@@ -0,0 +1,78 @@
+package adapter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLobbyAdapter(t *testing.T, status int) *LobbyAdapter {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("CHAT_SERVER_URL", server.URL)
+	t.Setenv("LOBBY_RETRIES", "0")
+	t.Setenv("LOBBY_BREAKER_THRESHOLD", "1")
+	adapter, err := NewLobbyAdapter()
+	assert.Nil(t, err)
+	return adapter
+}
+
+func TestLobbyAdapterPing_Reachable(t *testing.T) {
+	adapter := newTestLobbyAdapter(t, http.StatusNotFound)
+
+	assert.Nil(t, adapter.Ping(newTestContext()))
+}
+
+func TestLobbyAdapterPing_ServerError(t *testing.T) {
+	adapter := newTestLobbyAdapter(t, http.StatusBadGateway)
+
+	assert.ErrorIs(t, adapter.Ping(newTestContext()), ErrLobbyServiceUnavailable)
+}
+
+func TestLobbyAdapterPing_BreakerOpen(t *testing.T) {
+	adapter := newTestLobbyAdapter(t, http.StatusNotFound)
+	adapter.breaker.failure()
+
+	assert.ErrorIs(t, adapter.Ping(newTestContext()), ErrLobbyServiceUnavailable)
+}
+
+func TestLobbyAdapter_RefreshFailuresKeepPlayersAvailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"id":"` + uuid.NewString() + `"}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("CHAT_SERVER_URL", server.URL)
+	t.Setenv("LOBBY_BREAKER_THRESHOLD", "1")
+	adapter, err := NewLobbyAdapter()
+	assert.Nil(t, err)
+
+	assert.ErrorIs(t, adapter.UpdatePlayerLastRefresh(newTestContext(), uuid.New()), ErrLobbyServiceUnavailable)
+	assert.ErrorIs(t, adapter.UpdatePlayerLastRefresh(newTestContext(), uuid.New()), ErrLobbyServiceUnavailable)
+	_, err = adapter.GetPlayer(newTestContext(), uuid.New())
+	assert.Nil(t, err)
+	assert.Nil(t, adapter.Ping(newTestContext()))
+}
+
+func TestNewLobbyAdapter_InvalidConfig(t *testing.T) {
+	for name, config := range map[string][2]string{
+		"timeout zero":     {"LOBBY_TIMEOUT_MS", "0"},
+		"negative retries": {"LOBBY_RETRIES", "-1"},
+		"backoff zero":     {"LOBBY_RETRY_BACKOFF_MS", "0"},
+		"negative backoff": {"LOBBY_RETRY_BACKOFF_MS", "-100"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(config[0], config[1])
+			_, err := NewLobbyAdapter()
+			assert.NotNil(t, err)
+		})
+	}
+}
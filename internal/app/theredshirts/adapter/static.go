@@ -0,0 +1,78 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+)
+
+type (
+	// StaticPlayerDirectory knows a fixed set of players, it replaces the lobby service when the message service runs standalone or in tests.
+	StaticPlayerDirectory struct {
+		mutex   sync.RWMutex
+		players map[uuid.UUID]SimplePlayer
+	}
+)
+
+func NewStaticPlayerDirectory(players ...*SimplePlayer) *StaticPlayerDirectory {
+	directory := &StaticPlayerDirectory{players: make(map[uuid.UUID]SimplePlayer)}
+	for _, player := range players {
+		directory.PutPlayer(player)
+	}
+	return directory
+}
+
+func newStaticPlayerDirectoryFromFile(path string) (*StaticPlayerDirectory, error) {
+	if path == "" {
+		return NewStaticPlayerDirectory(), nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading players from %s: %v", path, err)
+	}
+	var players []*SimplePlayer
+	if err := json.Unmarshal(content, &players); err != nil {
+		return nil, fmt.Errorf("error while parsing players from %s: %v", path, err)
+	}
+	return NewStaticPlayerDirectory(players...), nil
+}
+
+func (directory *StaticPlayerDirectory) PutPlayer(player *SimplePlayer) {
+	directory.mutex.Lock()
+	defer directory.mutex.Unlock()
+	directory.players[player.ID] = *player
+}
+
+func (directory *StaticPlayerDirectory) RemovePlayer(playerId uuid.UUID) {
+	directory.mutex.Lock()
+	defer directory.mutex.Unlock()
+	delete(directory.players, playerId)
+}
+
+func (directory *StaticPlayerDirectory) GetPlayer(context *util.Context, playerId uuid.UUID) (*SimplePlayer, error) {
+	directory.mutex.RLock()
+	defer directory.mutex.RUnlock()
+	player, ok := directory.players[playerId]
+	if !ok {
+		return nil, ErrPlayerNotFound
+	}
+	return &player, nil
+}
+
+func (directory *StaticPlayerDirectory) UpdatePlayerLastRefresh(context *util.Context, playerId uuid.UUID) error {
+	directory.mutex.RLock()
+	defer directory.mutex.RUnlock()
+	if _, ok := directory.players[playerId]; !ok {
+		return ErrPlayerNotFound
+	}
+	return nil
+}
+
+// Ping always succeeds, the players are kept in memory.
+func (directory *StaticPlayerDirectory) Ping(context *util.Context) error {
+	return nil
+}
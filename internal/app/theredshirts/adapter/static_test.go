@@ -0,0 +1,37 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticPlayerDirectoryGetPlayer_Found(t *testing.T) {
+	somePlayer := &SimplePlayer{ID: uuid.New(), Name: "some player", LobbyId: uuid.New()}
+	directory := NewStaticPlayerDirectory(somePlayer)
+
+	player, err := directory.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, somePlayer, player)
+	assert.Nil(t, directory.UpdatePlayerLastRefresh(newTestContext(), somePlayer.ID))
+}
+
+func TestStaticPlayerDirectoryGetPlayer_NotFound(t *testing.T) {
+	directory := NewStaticPlayerDirectory(&SimplePlayer{ID: uuid.New()})
+
+	_, err := directory.GetPlayer(newTestContext(), uuid.New())
+	assert.ErrorIs(t, err, ErrPlayerNotFound)
+	assert.ErrorIs(t, directory.UpdatePlayerLastRefresh(newTestContext(), uuid.New()), ErrPlayerNotFound)
+}
+
+func TestStaticPlayerDirectoryPutPlayer_Overwrite(t *testing.T) {
+	somePlayer := &SimplePlayer{ID: uuid.New(), Name: "some player", LobbyId: uuid.New()}
+	directory := NewStaticPlayerDirectory(somePlayer)
+
+	movedPlayer := &SimplePlayer{ID: somePlayer.ID, Name: "some player", LobbyId: uuid.New()}
+	directory.PutPlayer(movedPlayer)
+	player, err := directory.GetPlayer(newTestContext(), somePlayer.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, movedPlayer.LobbyId, player.LobbyId)
+}
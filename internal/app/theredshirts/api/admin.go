@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/core"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/labstack/echo/v4"
+)
+
+const admin_root_path = "/admin"
+const leader_path = "/leader"
+
+type (
+	Leader struct {
+		InstanceId       string `json:"instance_id"`
+		Leader           bool   `json:"leader"`
+		LeaderInstanceId string `json:"leader_instance_id"`
+	}
+)
+
+func initAdminInterface(group *echo.Group, api *EchoApi) {
+	group.GET(leader_path, api.getLeader)
+}
+
+func (api *EchoApi) getLeader(context echo.Context) error {
+	customContext := context.Get(context_key).(*util.Context)
+	logger := customContext.Logger
+	logger.Debug("Get leader")
+
+	leader, err := api.core.GetLeader(customContext, getPlayer(context).PlayerId)
+	if err != nil {
+		logger.Warnf("Error while loading leader: %v", err)
+		return mapError(err)
+	}
+	return context.JSON(http.StatusOK, mapToLeader(leader))
+}
+
+func mapToLeader(leader *core.Leader) *Leader {
+	return &Leader{InstanceId: leader.InstanceId, Leader: leader.Leader, LeaderInstanceId: leader.LeaderInstanceId}
+}
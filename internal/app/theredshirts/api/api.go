@@ -1,7 +1,15 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/core"
 	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
@@ -24,7 +32,17 @@ type (
 		validator *validator.Validate
 	}
 	EchoApi struct {
-		core core.Core
+		core    core.Core
+		limiter *rateLimiter
+		// shutdown is closed when the server shuts down, open streams end with it
+		shutdown chan struct{}
+		// streamInterval is the time between heartbeats of open streams
+		streamInterval time.Duration
+		// streams counts the open streams, websockets are hijacked and not awaited by the server
+		streams sync.WaitGroup
+		// streamsLock guards stopping, so no stream is added to streams while waiting for them
+		streamsLock sync.Mutex
+		stopping    bool
 	}
 	Api interface {
 	}
@@ -32,24 +50,58 @@ type (
 
 func NewApi() (Api, error) {
 	initLogger()
+	tlsConfig, err := loadTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error while loading tls config: %v", err)
+	}
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		return nil, fmt.Errorf("error while initializing authentication: %v", err)
+	}
+	systemAuthenticator, err := newSystemAuthenticator(tlsConfig != nil && tlsConfig.ClientCAs != nil)
+	if err != nil {
+		return nil, fmt.Errorf("error while initializing authentication of lobby service: %v", err)
+	}
+
+	limiter, err := newRateLimiter()
+	if err != nil {
+		return nil, fmt.Errorf("error while initializing rate limiter: %v", err)
+	}
+
 	core, err := core.NewCore()
 	if err != nil {
 		return nil, fmt.Errorf("error while creating core layer: %v", err)
 	}
 
-	echoApi := &EchoApi{core: core}
+	shutdownTimeout, err := util.GetEnvIntWithFallback("SHUTDOWN_TIMEOUT", 20)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading shutdown timeout from environment variable: %v", err)
+	}
+
+	echoApi := &EchoApi{core: core, limiter: limiter, shutdown: make(chan struct{}), streamInterval: stream_refresh_interval}
 	e := echo.New()
 	e.HideBanner = true
 	e.AutoTLSManager.Cache = autocert.DirCache("/var/www/.cache")
+	e.Pre(accessTokenMiddleware)
 	e.Use(middleware.CORS(), middleware.Recover())
 	e.Validator = &CustomValidator{validator: validator.New()}
+	e.HTTPErrorHandler = problemErrorHandler
 
 	c := jaegertracing.New(e, nil)
 	defer c.Close()
 
-	chatGroup := e.Group(message_root_path, setContextMiddleware)
+	chatGroup := e.Group(message_root_path, setContextMiddleware, authMiddleware(authenticator, systemAuthenticator))
 	initChatInterface(chatGroup, echoApi)
 
+	playerGroup := e.Group(player_root_path, setContextMiddleware, authMiddleware(authenticator, systemAuthenticator))
+	initPlayerInterface(playerGroup, echoApi)
+
+	adminGroup := e.Group(admin_root_path, setContextMiddleware, authMiddleware(authenticator, systemAuthenticator))
+	initAdminInterface(adminGroup, echoApi)
+
+	healthGroup := e.Group(health_root_path, setContextMiddleware)
+	initHealthInterface(healthGroup, echoApi)
+
 	prom := prometheus.NewPrometheus("message", nil)
 	prom.Use(e)
 
@@ -59,11 +111,62 @@ func NewApi() (Api, error) {
 		return nil, fmt.Errorf("error while loading port from environment variable: %v", err)
 	}
 	url := fmt.Sprintf("%s:%d", address, port)
-	e.Logger.Fatal(e.Start(url))
+
+	server := e.Server
+	if tlsConfig != nil {
+		server = e.TLSServer
+		server.TLSConfig = tlsConfig
+	}
+	server.Addr = url
+	server.RegisterOnShutdown(func() {
+		echoApi.closeStreams()
+		core.Shutdown()
+	})
+	signalContext, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	started := make(chan error, 1)
+	go func() {
+		started <- e.StartServer(server)
+	}()
+
+	select {
+	case err := <-started:
+		core.Close()
+		if !errors.Is(err, http.ErrServerClosed) {
+			return nil, fmt.Errorf("error while running server: %v", err)
+		}
+	case <-signalContext.Done():
+		log.Info("Shutting down server")
+		echoApi.stop(e, time.Duration(shutdownTimeout)*time.Second)
+	}
 
 	return echoApi, nil
 }
 
+// stop stops accepting requests, waits until running requests and streams are done or the timeout elapsed and closes the core afterwards.
+func (api *EchoApi) stop(e *echo.Echo, timeout time.Duration) {
+	shutdownContext, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := e.Shutdown(shutdownContext); err != nil {
+		log.Warnf("Error while waiting for running requests: %v", err)
+	}
+
+	api.closeStreams()
+	streamsDone := make(chan struct{})
+	go func() {
+		api.streams.Wait()
+		close(streamsDone)
+	}()
+	select {
+	case <-streamsDone:
+	case <-shutdownContext.Done():
+		log.Warn("Timeout while waiting for open streams")
+	}
+
+	api.core.Close()
+	log.Info("Server stopped")
+}
+
 func (cv *CustomValidator) Validate(i interface{}) error {
 	return cv.validator.Struct(i)
 }
@@ -80,7 +183,29 @@ func setContextMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 			correlation_id_header: correlationId,
 		})
 
-		c.Set(context_key, &util.Context{CorrelationId: correlationId, Logger: logger})
+		c.Set(context_key, &util.Context{CorrelationId: correlationId, Logger: logger, Request: c.Request().Context()})
 		return next(c)
 	}
 }
+
+// startStream adds a stream to the open streams unless the server is shutting down.
+func (api *EchoApi) startStream() bool {
+	api.streamsLock.Lock()
+	defer api.streamsLock.Unlock()
+	if api.stopping {
+		return false
+	}
+	api.streams.Add(1)
+	return true
+}
+
+// closeStreams refuses new streams and ends the open ones, it can be called more than once.
+func (api *EchoApi) closeStreams() {
+	api.streamsLock.Lock()
+	defer api.streamsLock.Unlock()
+	if api.stopping {
+		return
+	}
+	api.stopping = true
+	close(api.shutdown)
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartStream_Running(t *testing.T) {
+	api := &EchoApi{shutdown: make(chan struct{})}
+	assert.True(t, api.startStream())
+	api.streams.Done()
+}
+
+func TestStartStream_ShuttingDown(t *testing.T) {
+	api := &EchoApi{shutdown: make(chan struct{})}
+	api.closeStreams()
+	api.closeStreams()
+
+	assert.False(t, api.startStream())
+	_, open := <-api.shutdown
+	assert.False(t, open)
+	api.streams.Wait()
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/core"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	player_key         = "player"
+	bearer_prefix      = "bearer "
+	access_token_param = "access_token"
+	lobby_id_claim     = "lobby_id"
+)
+
+type (
+	// authenticator identifies the player sending a request.
+	authenticator interface {
+		authenticate(request *http.Request) (*authenticatedPlayer, error)
+		// challenge is sent in the WWW-Authenticate header if the authentication failed
+		challenge() string
+	}
+
+	authenticatedPlayer struct {
+		PlayerId uuid.UUID
+		// System is true if the request was sent by the lobby service, only then the player is the lobby user
+		System bool
+		// LobbyId restricts the player to one lobby, it is uuid.Nil if the player is not restricted
+		LobbyId uuid.UUID
+		// Expires is the time the authentication of the player ends, streams are closed then. It is zero if it does not expire.
+		Expires time.Time
+	}
+
+	// jwtAuthenticator expects a signed token with the player as subject and optionally the lobby in the lobby_id claim.
+	// The token is sent as bearer token, streams can pass it as access_token query parameter because browsers can not set headers for them,
+	// accessTokenMiddleware moves it into the header.
+	jwtAuthenticator struct {
+		parser   *jwt.Parser
+		keys     *keySet
+		audience string
+		issuer   string
+	}
+
+	// headerAuthenticator trusts the playerId header, it is only meant for local development.
+	headerAuthenticator struct {
+	}
+)
+
+func newAuthenticator() (authenticator, error) {
+	insecure, err := util.GetEnvBoolWithFallback("AUTH_INSECURE_HEADER", false)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading insecure header flag from environment variable: %v", err)
+	}
+	if insecure {
+		log.Warn("Players are identified by the playerId header without any authentication, never use this outside of local development")
+		return &headerAuthenticator{}, nil
+	}
+
+	keySetFile, err := util.GetEnv("AUTH_JWT_KEY_SET_FILE")
+	if err != nil {
+		return nil, fmt.Errorf("key set for tokens has to be set: %v", err)
+	}
+	audience, err := util.GetEnv("AUTH_JWT_AUDIENCE")
+	if err != nil {
+		return nil, fmt.Errorf("audience of tokens has to be set: %v", err)
+	}
+	issuer := util.GetEnvWithFallback("AUTH_JWT_ISSUER", "")
+	keys, err := loadKeySet(keySetFile)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading key set: %v", err)
+	}
+	return newJwtAuthenticator(keys, audience, issuer), nil
+}
+
+func newJwtAuthenticator(keys *keySet, audience string, issuer string) *jwtAuthenticator {
+	return &jwtAuthenticator{parser: &jwt.Parser{ValidMethods: supportedSigningMethods}, keys: keys, audience: audience, issuer: issuer}
+}
+
+// accessTokenMiddleware moves the access_token query parameter of GET requests into the authorization header. It runs before tracing,
+// so the token is never part of a traced or logged url. The parameter is removed from other requests as well, they are not authenticated by it.
+func accessTokenMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		request := c.Request()
+		query := request.URL.Query()
+		if !query.Has(access_token_param) {
+			return next(c)
+		}
+		token := query.Get(access_token_param)
+		query.Del(access_token_param)
+		request.URL.RawQuery = query.Encode()
+		request.RequestURI = request.URL.RequestURI()
+		if request.Method == http.MethodGet && token != "" && request.Header.Get(echo.HeaderAuthorization) == "" {
+			request.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		return next(c)
+	}
+}
+
+// authMiddleware authenticates the lobby service first and players otherwise. A player claiming to be the lobby user is rejected,
+// the identity of the lobby user is only granted to the lobby service.
+func authMiddleware(authenticator authenticator, systemAuthenticator *systemAuthenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			logger := c.Get(context_key).(*util.Context).Logger
+			system, err := systemAuthenticator.authenticate(c.Request())
+			if err != nil {
+				logger.Warnf("Error while authenticating lobby service: %v", err)
+				return newProblem(http.StatusUnauthorized, problem_type_unauthorized, "The request could not be authenticated.")
+			}
+			if system {
+				c.Set(player_key, &authenticatedPlayer{PlayerId: systemAuthenticator.lobbyUserId, System: true})
+				return next(c)
+			}
+
+			player, err := authenticator.authenticate(c.Request())
+			if err == nil && player.PlayerId == systemAuthenticator.lobbyUserId {
+				err = errors.New("lobby user has to authenticate as lobby service")
+			}
+			if err != nil {
+				logger.Warnf("Error while authenticating request: %v", err)
+				if challenge := authenticator.challenge(); challenge != "" {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+				}
+				return newProblem(http.StatusUnauthorized, problem_type_unauthorized, "The request could not be authenticated.")
+			}
+			c.Set(player_key, player)
+			return next(c)
+		}
+	}
+}
+
+func (authenticator *jwtAuthenticator) authenticate(request *http.Request) (*authenticatedPlayer, error) {
+	tokenString, err := getBearerToken(request)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := authenticator.parser.ParseWithClaims(tokenString, claims, authenticator.keys.keyFunc); err != nil {
+		return nil, fmt.Errorf("token is not valid: %v", err)
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("token has no expiry")
+	}
+	if !claims.VerifyAudience(authenticator.audience, true) {
+		return nil, fmt.Errorf("token is not issued for audience %s", authenticator.audience)
+	}
+	if authenticator.issuer != "" && !claims.VerifyIssuer(authenticator.issuer, true) {
+		return nil, fmt.Errorf("token is not issued by %s", authenticator.issuer)
+	}
+
+	subject, _ := claims["sub"].(string)
+	playerId, err := uuid.Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("subject of token is no player id: %v", err)
+	}
+	player := &authenticatedPlayer{PlayerId: playerId, Expires: getExpiry(claims)}
+	if claim, ok := claims[lobby_id_claim]; ok {
+		lobbyId, _ := claim.(string)
+		player.LobbyId, err = uuid.Parse(lobbyId)
+		if err != nil {
+			return nil, fmt.Errorf("lobby of token is no lobby id: %v", err)
+		}
+	}
+	return player, nil
+}
+
+// getExpiry returns the time of the exp claim, which was already verified to exist.
+func getExpiry(claims jwt.MapClaims) time.Time {
+	switch exp := claims["exp"].(type) {
+	case float64:
+		return time.Unix(int64(exp), 0)
+	case json.Number:
+		seconds, _ := exp.Int64()
+		return time.Unix(seconds, 0)
+	}
+	return time.Time{}
+}
+
+func (authenticator *jwtAuthenticator) challenge() string {
+	return `Bearer error="invalid_token"`
+}
+
+func getBearerToken(request *http.Request) (string, error) {
+	authorization := request.Header.Get(echo.HeaderAuthorization)
+	if authorization != "" {
+		if len(authorization) <= len(bearer_prefix) || !strings.EqualFold(authorization[:len(bearer_prefix)], bearer_prefix) {
+			return "", errors.New("authorization header contains no bearer token")
+		}
+		return authorization[len(bearer_prefix):], nil
+	}
+	return "", errors.New("request contains no token")
+}
+
+func (authenticator *headerAuthenticator) authenticate(request *http.Request) (*authenticatedPlayer, error) {
+	playerId, err := uuid.Parse(request.Header.Get(player_id_param))
+	if err != nil {
+		return nil, fmt.Errorf("error while binding playerId: %v", err)
+	}
+	return &authenticatedPlayer{PlayerId: playerId}, nil
+}
+
+func (authenticator *headerAuthenticator) challenge() string {
+	return ""
+}
+
+func getPlayer(context echo.Context) *authenticatedPlayer {
+	return context.Get(player_key).(*authenticatedPlayer)
+}
+
+// getLobbyPlayerId returns the id of the authenticated player. A player restricted to another lobby is rejected without asking the lobby service.
+func getLobbyPlayerId(context echo.Context, lobbyId uuid.UUID) (uuid.UUID, error) {
+	player := getPlayer(context)
+	if player.LobbyId != uuid.Nil && player.LobbyId != lobbyId {
+		return uuid.Nil, fmt.Errorf("%w: token of player %v is restricted to lobby %v", core.ErrNotLobbyMember, player.PlayerId, player.LobbyId)
+	}
+	return player.PlayerId, nil
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/core"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	test_audience = "theredshirts-message"
+	test_secret   = "some-secret-which-is-long-enough"
+)
+
+func newTestAuthenticator(t *testing.T) *jwtAuthenticator {
+	keys, err := parseKeySet([]byte(fmt.Sprintf(`{"keys":[{"kid":"some-key","kty":"oct","alg":"HS256","k":"%s"}]}`, base64.RawURLEncoding.EncodeToString([]byte(test_secret)))))
+	assert.Nil(t, err)
+	return newJwtAuthenticator(keys, test_audience, "")
+}
+
+func signTestToken(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "some-key"
+	signed, err := token.SignedString([]byte(test_secret))
+	assert.Nil(t, err)
+	return signed
+}
+
+func newTestRequest(method string, target string, token string) *http.Request {
+	request := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		request.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	return request
+}
+
+func TestJwtAuthenticator_Successfully(t *testing.T) {
+	somePlayerId := uuid.New()
+	someLobbyId := uuid.New()
+	token := signTestToken(t, jwt.MapClaims{"sub": somePlayerId.String(), lobby_id_claim: someLobbyId.String(), "aud": test_audience, "exp": time.Now().Add(time.Minute).Unix()})
+
+	player, err := newTestAuthenticator(t).authenticate(newTestRequest(http.MethodPost, "/message", token))
+	assert.Nil(t, err)
+	assert.Equal(t, somePlayerId, player.PlayerId)
+	assert.Equal(t, someLobbyId, player.LobbyId)
+}
+
+// moveAccessToken runs the request through accessTokenMiddleware.
+func moveAccessToken(t *testing.T, request *http.Request) *http.Request {
+	handler := accessTokenMiddleware(func(c echo.Context) error { return nil })
+	assert.Nil(t, handler(echo.New().NewContext(request, httptest.NewRecorder())))
+	return request
+}
+
+func TestJwtAuthenticator_AccessTokenParam(t *testing.T) {
+	somePlayerId := uuid.New()
+	token := signTestToken(t, jwt.MapClaims{"sub": somePlayerId.String(), "aud": test_audience, "exp": time.Now().Add(time.Minute).Unix()})
+	authenticator := newTestAuthenticator(t)
+
+	request := moveAccessToken(t, newTestRequest(http.MethodGet, "/message?wait=5&access_token="+token, ""))
+	player, err := authenticator.authenticate(request)
+	assert.Nil(t, err)
+	assert.Equal(t, somePlayerId, player.PlayerId)
+	assert.Equal(t, uuid.Nil, player.LobbyId)
+	assert.Equal(t, "/message?wait=5", request.URL.String())
+	assert.Equal(t, "/message?wait=5", request.RequestURI)
+
+	request = moveAccessToken(t, newTestRequest(http.MethodPut, "/message?access_token="+token, ""))
+	_, err = authenticator.authenticate(request)
+	assert.NotNil(t, err)
+	assert.Equal(t, "/message", request.URL.String())
+}
+
+func TestJwtAuthenticator_Rejected(t *testing.T) {
+	somePlayerId := uuid.New().String()
+	expires := time.Now().Add(time.Minute).Unix()
+	for name, token := range map[string]string{
+		"expired":        signTestToken(t, jwt.MapClaims{"sub": somePlayerId, "aud": test_audience, "exp": time.Now().Add(-time.Minute).Unix()}),
+		"no expiry":      signTestToken(t, jwt.MapClaims{"sub": somePlayerId, "aud": test_audience}),
+		"wrong audience": signTestToken(t, jwt.MapClaims{"sub": somePlayerId, "aud": "other-service", "exp": expires}),
+		"no player":      signTestToken(t, jwt.MapClaims{"sub": "someone", "aud": test_audience, "exp": expires}),
+		"wrong lobby":    signTestToken(t, jwt.MapClaims{"sub": somePlayerId, lobby_id_claim: "somewhere", "aud": test_audience, "exp": expires}),
+		"wrong key":      signTestToken(t, jwt.MapClaims{"sub": somePlayerId, "aud": test_audience, "exp": expires})[1:],
+		"unsigned":       "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." + base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"%s","aud":"%s","exp":%d}`, somePlayerId, test_audience, expires))) + ".",
+		"missing":        "",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := newTestAuthenticator(t).authenticate(newTestRequest(http.MethodGet, "/message", token))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestJwtAuthenticator_RsaKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	keys, err := parseKeySet([]byte(fmt.Sprintf(`{"keys":[{"kty":"RSA","n":"%s","e":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()), base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()))))
+	assert.Nil(t, err)
+	somePlayerId := uuid.New()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": somePlayerId.String(), "aud": test_audience, "exp": time.Now().Add(time.Minute).Unix()}).SignedString(privateKey)
+	assert.Nil(t, err)
+
+	player, err := newJwtAuthenticator(keys, test_audience, "").authenticate(newTestRequest(http.MethodGet, "/message", token))
+	assert.Nil(t, err)
+	assert.Equal(t, somePlayerId, player.PlayerId)
+}
+
+func TestGetLobbyPlayerId_OtherLobby(t *testing.T) {
+	context := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/message", nil), httptest.NewRecorder())
+	context.Set(player_key, &authenticatedPlayer{PlayerId: uuid.New(), LobbyId: uuid.New()})
+
+	_, err := getLobbyPlayerId(context, uuid.New())
+	assert.ErrorIs(t, err, core.ErrNotLobbyMember)
+}
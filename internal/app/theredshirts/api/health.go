@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/labstack/echo/v4"
+)
+
+const health_root_path = "/health"
+const live_path = "/live"
+const ready_path = "/ready"
+
+const (
+	health_status_up   = "UP"
+	health_status_down = "DOWN"
+)
+
+type (
+	Health struct {
+		Status string                  `json:"status"`
+		Checks map[string]*HealthCheck `json:"checks,omitempty"`
+	}
+
+	HealthCheck struct {
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}
+)
+
+func initHealthInterface(group *echo.Group, api *EchoApi) {
+	group.GET(live_path, api.getLiveness)
+	group.GET(ready_path, api.getReadiness)
+}
+
+// getLiveness only tells that the service is running, failing dependencies are no reason to restart it.
+func (api *EchoApi) getLiveness(context echo.Context) error {
+	return context.JSON(http.StatusOK, &Health{Status: health_status_up})
+}
+
+func (api *EchoApi) getReadiness(context echo.Context) error {
+	customContext := context.Get(context_key).(*util.Context)
+	logger := customContext.Logger
+	logger.Debug("Check readiness")
+
+	health := mapToHealth(api.core.CheckHealth(customContext))
+	if health.Status != health_status_up {
+		logger.Warnf("Service is not ready: %+v", health.Checks)
+		return context.JSON(http.StatusServiceUnavailable, health)
+	}
+	return context.JSON(http.StatusOK, health)
+}
+
+func mapToHealth(checks map[string]error) *Health {
+	health := &Health{Status: health_status_up, Checks: make(map[string]*HealthCheck, len(checks))}
+	for name, err := range checks {
+		if err != nil {
+			health.Status = health_status_down
+			health.Checks[name] = &HealthCheck{Status: health_status_down, Error: err.Error()}
+			continue
+		}
+		health.Checks[name] = &HealthCheck{Status: health_status_up}
+	}
+	return health
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt"
+)
+
+type (
+	// keySet holds the keys to verify tokens with, loaded from a JSON Web Key Set as described in RFC 7517.
+	keySet struct {
+		keys map[string]*verificationKey
+	}
+
+	verificationKey struct {
+		// algorithm restricts the key to one signing method, empty allows every method of the key type
+		algorithm string
+		key       interface{}
+	}
+
+	jsonWebKeySet struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+
+	jsonWebKey struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Alg string `json:"alg"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		K   string `json:"k"`
+	}
+)
+
+var supportedSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "HS256", "HS384", "HS512"}
+
+func loadKeySet(path string) (*keySet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading key set from %s: %v", path, err)
+	}
+	return parseKeySet(content)
+}
+
+func parseKeySet(content []byte) (*keySet, error) {
+	var jwks jsonWebKeySet
+	if err := json.Unmarshal(content, &jwks); err != nil {
+		return nil, fmt.Errorf("error while parsing key set: %v", err)
+	}
+
+	set := &keySet{keys: make(map[string]*verificationKey)}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJsonWebKey(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing key %s: %v", jwk.Kid, err)
+		}
+		if _, ok := set.keys[jwk.Kid]; ok {
+			return nil, fmt.Errorf("key id %s is used more than once", jwk.Kid)
+		}
+		set.keys[jwk.Kid] = &verificationKey{algorithm: jwk.Alg, key: key}
+	}
+	if len(set.keys) == 0 {
+		return nil, fmt.Errorf("key set contains no key for signatures")
+	}
+	return set, nil
+}
+
+func parseJsonWebKey(jwk *jsonWebKey) (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("error while decoding modulus: %v", err)
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("error while decoding exponent: %v", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curve %s is not supported", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("error while decoding x: %v", err)
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("error while decoding y: %v", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil {
+			return nil, fmt.Errorf("error while decoding secret: %v", err)
+		}
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("secret is empty")
+		}
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("key type %s is not supported", jwk.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes) == 0 {
+		return nil, fmt.Errorf("value is empty")
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+// keyFunc returns the key matching the key id of the token. Tokens without key id are only accepted if the set has a single key.
+func (set *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := set.keys[kid]
+	if !ok && kid == "" && len(set.keys) == 1 {
+		for _, onlyKey := range set.keys {
+			key, ok = onlyKey, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("no key with id %s found", kid)
+	}
+	if key.algorithm != "" && key.algorithm != token.Method.Alg() {
+		return nil, fmt.Errorf("key %s is not allowed for signing method %s", kid, token.Method.Alg())
+	}
+	return key.key, nil
+}
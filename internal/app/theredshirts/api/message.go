@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/core"
@@ -20,15 +21,31 @@ const player_id_param = "playerId"
 
 type (
 	MessageCreate struct {
+		ID      uuid.UUID `param:"messageId" validate:"required"`
+		LobbyId uuid.UUID `param:"lobbyId" validate:"required"`
+		// PlayerId is only set by the lobby user to write in the name of a player
+		PlayerId uuid.UUID              `json:"player_id"`
+		Topic    string                 `json:"topic" validate:"required"`
+		Message  map[string]interface{} `json:"message"`
+		// Recipients make the message direct, only they get the message then
+		Recipients []uuid.UUID `json:"recipients" validate:"max=50"`
+		Channel    string      `json:"channel" validate:"omitempty,oneof=lobby team spectators"`
+	}
+
+	MessageEdit struct {
 		ID      uuid.UUID              `param:"messageId" validate:"required"`
 		LobbyId uuid.UUID              `param:"lobbyId" validate:"required"`
-		Topic   string                 `json:"topic" validate:"required"`
 		Message map[string]interface{} `json:"message"`
 	}
 
+	LobbyDelete struct {
+		LobbyId uuid.UUID `param:"lobbyId" validate:"required"`
+	}
+
 	MessageGet struct {
 		LobbyId uuid.UUID `param:"lobbyId" validate:"required"`
 		Number  int       `param:"number" validate:"required"`
+		Wait    int       `query:"wait" validate:"min=0"`
 	}
 
 	Message struct {
@@ -38,13 +55,20 @@ type (
 		Number   int                    `json:"number"`
 		Topic    string                 `json:"topic"`
 		Message  map[string]interface{} `json:"message"`
+		Direct   bool                   `json:"direct"`
+		Channel  string                 `json:"channel"`
+		Revision int                    `json:"revision"`
 	}
 )
 
 func initChatInterface(group *echo.Group, api *EchoApi) {
+	group.DELETE("/:"+lobby_id_param, api.deleteLobbyMessages)
 	group.POST("/:"+lobby_id_param+message_path, api.createMessageId)
 	group.PUT("/:"+lobby_id_param+message_path+"/:"+message_id_param, api.createMessage)
+	group.PATCH("/:"+lobby_id_param+message_path+"/:"+message_id_param, api.editMessage)
 	group.GET("/:"+lobby_id_param+message_path+"/:"+number_id_param, api.getMessages)
+	group.GET("/:"+lobby_id_param+websocket_path+"/:"+number_id_param, api.streamMessagesWebSocket)
+	group.GET("/:"+lobby_id_param+event_path+"/:"+number_id_param, api.streamMessagesEvents)
 }
 
 func (api *EchoApi) createMessageId(context echo.Context) error {
@@ -62,25 +86,85 @@ func (api *EchoApi) createMessage(context echo.Context) error {
 	message, err := bindMessageCreationDTO(context)
 	if err != nil {
 		logger.Warnf("Error while binding message: %v", err)
-		return echo.ErrBadRequest
+		return mapRequestError(err)
 	}
-	playerId, err := getHeaderPlayerId(context)
+	playerId, err := getLobbyPlayerId(context, message.LobbyId)
 	if err != nil {
-		logger.Warnf("Error while binding playerId: %v", err)
-		return echo.ErrBadRequest
+		logger.Warnf("Error while authorizing player: %v", err)
+		return mapError(err)
 	}
 
-	coreMessage := mapMessageCreateToMessage(message, playerId)
-	err = api.core.CreateMessage(customContext, coreMessage)
+	if err := api.checkRateLimit(context, playerId, message.LobbyId, message.Topic); err != nil {
+		return err
+	}
+
+	coreMessage := mapMessageCreateToMessage(message)
+	err = api.core.CreateMessage(customContext, playerId, coreMessage)
 
 	if err != nil {
 		logger.Warnf("Error while creating message: %v", err)
-		return echo.ErrInternalServerError
+		return mapError(err)
 	}
 
 	return context.NoContent(http.StatusCreated)
 }
 
+func (api *EchoApi) editMessage(context echo.Context) error {
+	customContext := context.Get(context_key).(*util.Context)
+	logger := customContext.Logger
+	logger.Debug("Edit message")
+
+	message, err := bindMessageEdit(context)
+	if err != nil {
+		logger.Warnf("Error while binding message: %v", err)
+		return mapRequestError(err)
+	}
+	playerId, err := getLobbyPlayerId(context, message.LobbyId)
+	if err != nil {
+		logger.Warnf("Error while authorizing player: %v", err)
+		return mapError(err)
+	}
+
+	// every edit is sent to all players again, so it counts to the limit of the topic of the message
+	if !getPlayer(context).System {
+		topic, err := api.core.GetMessageTopic(customContext, playerId, message.LobbyId, message.ID)
+		if err != nil {
+			logger.Warnf("Error while loading topic of message: %v", err)
+			return mapError(err)
+		}
+		if err := api.checkRateLimit(context, playerId, message.LobbyId, topic); err != nil {
+			return err
+		}
+	}
+
+	coreMessage := mapMessageEditToMessage(message)
+	if err := api.core.EditMessage(customContext, playerId, coreMessage); err != nil {
+		logger.Warnf("Error while editing message: %v", err)
+		return mapError(err)
+	}
+	return context.JSON(http.StatusOK, mapToMessage(coreMessage))
+}
+
+// checkRateLimit takes a token of the player and the lobby for a message, the lobby service is not limited.
+// The lobby is only charged for its members, otherwise anyone could exhaust the limit of a foreign lobby.
+func (api *EchoApi) checkRateLimit(context echo.Context, playerId uuid.UUID, lobbyId uuid.UUID, topic string) error {
+	if getPlayer(context).System {
+		return nil
+	}
+	customContext := context.Get(context_key).(*util.Context)
+	logger := customContext.Logger
+	if err := api.core.CheckLobbyMember(customContext, playerId, lobbyId); err != nil {
+		logger.Warnf("Error while authorizing player: %v", err)
+		return mapError(err)
+	}
+	if allowed, retryAfter := api.limiter.allow(playerId, lobbyId, topic); !allowed {
+		logger.Infof("Rate limit of player %v exceeded, retry after %v", playerId, retryAfter)
+		context.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(retryAfterSeconds(retryAfter)))
+		return newProblem(http.StatusTooManyRequests, problem_type_rate_limited, "Too many messages, retry later.")
+	}
+	return nil
+}
+
 func (api *EchoApi) getMessages(context echo.Context) error {
 	customContext := context.Get(context_key).(*util.Context)
 	logger := customContext.Logger
@@ -89,30 +173,66 @@ func (api *EchoApi) getMessages(context echo.Context) error {
 	message, err := bindMessageGet(context)
 	if err != nil {
 		logger.Warnf("Error while binding get message: %v", err)
-		return echo.ErrBadRequest
+		return mapRequestError(err)
 	}
 
-	playerId, err := getHeaderPlayerId(context)
+	playerId, err := getLobbyPlayerId(context, message.LobbyId)
 	if err != nil {
-		logger.Warnf("Error while binding playerId: %v", err)
-		return echo.ErrBadRequest
+		logger.Warnf("Error while authorizing player: %v", err)
+		return mapError(err)
 	}
 
-	messages, err := api.core.GetMessages(customContext, playerId, message.LobbyId, message.Number)
+	messages, err := api.core.GetMessages(customContext, playerId, message.LobbyId, message.Number, time.Duration(message.Wait)*time.Second)
 	if err != nil {
 		logger.Warnf("Error while loading messages: %v", err)
-		return echo.ErrInternalServerError
+		return mapError(err)
 	}
 	return context.JSON(http.StatusOK, mapToMessages(messages))
 }
 
+func (api *EchoApi) deleteLobbyMessages(context echo.Context) error {
+	customContext := context.Get(context_key).(*util.Context)
+	logger := customContext.Logger
+	logger.Debug("Delete messages of lobby")
+
+	lobby, err := bindLobbyDelete(context)
+	if err != nil {
+		logger.Warnf("Error while binding lobby: %v", err)
+		return mapRequestError(err)
+	}
+
+	playerId, err := getLobbyPlayerId(context, lobby.LobbyId)
+	if err != nil {
+		logger.Warnf("Error while authorizing player: %v", err)
+		return mapError(err)
+	}
+
+	if err := api.core.DeleteLobbyMessages(customContext, playerId, lobby.LobbyId); err != nil {
+		logger.Warnf("Error while deleting messages of lobby: %v", err)
+		return mapError(err)
+	}
+	return context.NoContent(http.StatusNoContent)
+}
+
 func bindMessageCreationDTO(context echo.Context) (message *MessageCreate, err error) {
 	message = new(MessageCreate)
 	if err := context.Bind(message); err != nil {
 		return nil, fmt.Errorf("could not bind message, %v", err)
 	}
 	if err := context.Validate(message); err != nil {
-		return nil, fmt.Errorf("could not validate message, %v", err)
+		return nil, fmt.Errorf("could not validate message, %w: %v", core.ErrValidation, err)
+	}
+
+	return message, nil
+}
+
+func bindMessageEdit(context echo.Context) (message *MessageEdit, err error) {
+	message = new(MessageEdit)
+	if err := context.Bind(message); err != nil {
+		return nil, fmt.Errorf("could not bind message, %v", err)
+	}
+	if err := context.Validate(message); err != nil {
+		return nil, fmt.Errorf("could not validate message, %w: %v", core.ErrValidation, err)
 	}
 
 	return message, nil
@@ -124,22 +244,30 @@ func bindMessageGet(context echo.Context) (message *MessageGet, err error) {
 		return nil, fmt.Errorf("could not bind message, %v", err)
 	}
 	if err := context.Validate(message); err != nil {
-		return nil, fmt.Errorf("could not validate message, %v", err)
+		return nil, fmt.Errorf("could not validate message, %w: %v", core.ErrValidation, err)
 	}
 
 	return message, nil
 }
 
-func getHeaderPlayerId(context echo.Context) (uuid.UUID, error) {
-	playerId, err := uuid.Parse(context.Request().Header.Get(player_id_param))
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("error while binding playerId: %v", err)
+func bindLobbyDelete(context echo.Context) (lobby *LobbyDelete, err error) {
+	lobby = new(LobbyDelete)
+	if err := context.Bind(lobby); err != nil {
+		return nil, fmt.Errorf("could not bind lobby, %v", err)
+	}
+	if err := context.Validate(lobby); err != nil {
+		return nil, fmt.Errorf("could not validate lobby, %w: %v", core.ErrValidation, err)
 	}
-	return playerId, nil
+
+	return lobby, nil
+}
+
+func mapMessageCreateToMessage(message *MessageCreate) *core.Message {
+	return &core.Message{ID: message.ID, PlayerId: message.PlayerId, SendTime: time.Now(), LobbyId: message.LobbyId, Topic: message.Topic, Message: message.Message, Recipients: message.Recipients, Channel: message.Channel}
 }
 
-func mapMessageCreateToMessage(message *MessageCreate, playerId uuid.UUID) *core.Message {
-	return &core.Message{ID: message.ID, PlayerId: playerId, SendTime: time.Now(), LobbyId: message.LobbyId, Topic: message.Topic, Message: message.Message}
+func mapMessageEditToMessage(message *MessageEdit) *core.Message {
+	return &core.Message{ID: message.ID, LobbyId: message.LobbyId, Message: message.Message}
 }
 
 func mapToMessages(coreMessages []*core.Message) []*Message {
@@ -151,5 +279,5 @@ func mapToMessages(coreMessages []*core.Message) []*Message {
 }
 
 func mapToMessage(message *core.Message) *Message {
-	return &Message{ID: message.ID, PlayerId: message.PlayerId, SendTime: message.SendTime, Number: message.Number, Topic: message.Topic, Message: message.Message}
+	return &Message{ID: message.ID, PlayerId: message.PlayerId, SendTime: message.SendTime, Number: message.Number, Topic: message.Topic, Message: message.Message, Direct: message.Direct, Channel: message.Channel, Revision: message.Revision}
 }
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const player_root_path = "/player"
+const player_cache_path = "/cache"
+
+func initPlayerInterface(group *echo.Group, api *EchoApi) {
+	group.DELETE("/:"+player_id_param+player_cache_path, api.invalidatePlayer)
+}
+
+func (api *EchoApi) invalidatePlayer(context echo.Context) error {
+	customContext := context.Get(context_key).(*util.Context)
+	logger := customContext.Logger
+	logger.Debug("Invalidate player")
+
+	playerId, err := uuid.Parse(context.Param(player_id_param))
+	if err != nil {
+		logger.Warnf("Error while binding player id: %v", err)
+		return mapRequestError(err)
+	}
+
+	requesterId := getPlayer(context).PlayerId
+	if err := api.core.InvalidatePlayer(customContext, requesterId, playerId); err != nil {
+		logger.Warnf("Error while invalidating player: %v", err)
+		return mapError(err)
+	}
+	return context.NoContent(http.StatusNoContent)
+}
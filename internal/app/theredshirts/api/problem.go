@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/core"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+const problem_content_type = "application/problem+json"
+
+const (
+	problem_type_prefix            = "urn:theredshirts:message:problem:"
+	problem_type_bad_request       = problem_type_prefix + "bad-request"
+	problem_type_unauthorized      = problem_type_prefix + "unauthorized"
+	problem_type_validation        = problem_type_prefix + "validation-failed"
+	problem_type_not_lobby_user    = problem_type_prefix + "not-lobby-user"
+	problem_type_not_lobby_member  = problem_type_prefix + "not-lobby-member"
+	problem_type_reserved_topic    = problem_type_prefix + "reserved-topic"
+	problem_type_player_not_found  = problem_type_prefix + "player-not-found"
+	problem_type_message_not_found = problem_type_prefix + "message-not-found"
+	problem_type_lobby_unavailable = problem_type_prefix + "lobby-service-unavailable"
+	problem_type_rate_limited      = problem_type_prefix + "rate-limited"
+	problem_type_shutting_down     = problem_type_prefix + "shutting-down"
+	problem_type_internal_error    = problem_type_prefix + "internal-error"
+	problem_type_unspecific        = "about:blank"
+)
+
+type (
+	// Problem is the body of every error response as described in RFC 7807.
+	Problem struct {
+		Type          string `json:"type"`
+		Title         string `json:"title"`
+		Status        int    `json:"status"`
+		Detail        string `json:"detail,omitempty"`
+		Instance      string `json:"instance,omitempty"`
+		CorrelationId string `json:"correlation_id,omitempty"`
+	}
+)
+
+func newProblem(status int, problemType string, detail string) *echo.HTTPError {
+	return echo.NewHTTPError(status, &Problem{Type: problemType, Title: http.StatusText(status), Status: status, Detail: detail})
+}
+
+// mapError returns the http error matching the error of the core layer.
+func mapError(err error) *echo.HTTPError {
+	switch {
+	case errors.Is(err, core.ErrValidation):
+		return newProblem(http.StatusUnprocessableEntity, problem_type_validation, err.Error())
+	case errors.Is(err, core.ErrNotLobbyUser):
+		return newProblem(http.StatusForbidden, problem_type_not_lobby_user, err.Error())
+	case errors.Is(err, core.ErrNotLobbyMember):
+		return newProblem(http.StatusForbidden, problem_type_not_lobby_member, err.Error())
+	case errors.Is(err, core.ErrReservedTopic):
+		return newProblem(http.StatusForbidden, problem_type_reserved_topic, err.Error())
+	case errors.Is(err, core.ErrPlayerNotFound):
+		return newProblem(http.StatusNotFound, problem_type_player_not_found, err.Error())
+	case errors.Is(err, core.ErrMessageNotFound):
+		return newProblem(http.StatusNotFound, problem_type_message_not_found, err.Error())
+	case errors.Is(err, core.ErrLobbyServiceUnavailable):
+		return newProblem(http.StatusServiceUnavailable, problem_type_lobby_unavailable, "The lobby service is not available, try again later.")
+	default:
+		return newProblem(http.StatusInternalServerError, problem_type_internal_error, "")
+	}
+}
+
+// mapRequestError returns the http error for a request which could not be bound, content failing the validation is unprocessable,
+// everything else is a bad request.
+func mapRequestError(err error) *echo.HTTPError {
+	if errors.Is(err, core.ErrValidation) {
+		return mapError(err)
+	}
+	return newProblem(http.StatusBadRequest, problem_type_bad_request, err.Error())
+}
+
+// problemErrorHandler writes every error as problem with the correlation id of the request.
+func problemErrorHandler(err error, context echo.Context) {
+	if context.Response().Committed {
+		return
+	}
+
+	problem := mapToProblem(err)
+	problem.Instance = context.Request().URL.Path
+	if customContext, ok := context.Get(context_key).(*util.Context); ok {
+		problem.CorrelationId = customContext.CorrelationId
+	} else {
+		problem.CorrelationId = context.Request().Header.Get(correlation_id_header)
+	}
+
+	if context.Request().Method == http.MethodHead {
+		err = context.NoContent(problem.Status)
+	} else {
+		err = writeProblem(context, problem)
+	}
+	if err != nil {
+		log.Warnf("Error while writing problem: %v", err)
+	}
+}
+
+func mapToProblem(err error) *Problem {
+	var httpError *echo.HTTPError
+	if !errors.As(err, &httpError) {
+		return &Problem{Type: problem_type_internal_error, Title: http.StatusText(http.StatusInternalServerError), Status: http.StatusInternalServerError}
+	}
+	if problem, ok := httpError.Message.(*Problem); ok {
+		copiedProblem := *problem
+		return &copiedProblem
+	}
+
+	// errors of echo itself, like an unknown route
+	problem := &Problem{Type: problem_type_unspecific, Title: http.StatusText(httpError.Code), Status: httpError.Code}
+	if detail := fmt.Sprint(httpError.Message); detail != problem.Title {
+		problem.Detail = detail
+	}
+	return problem
+}
+
+func writeProblem(context echo.Context, problem *Problem) error {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return fmt.Errorf("error while marshalling problem: %v", err)
+	}
+	return context.Blob(problem.Status, problem_content_type, body)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/core"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func handleTestError(err error) (*httptest.ResponseRecorder, *Problem) {
+	e := echo.New()
+	recorder := httptest.NewRecorder()
+	context := e.NewContext(httptest.NewRequest(http.MethodGet, "/message/lobby/msg/1", nil), recorder)
+	context.Set(context_key, &util.Context{CorrelationId: "some-correlation-id", Logger: log.WithFields(log.Fields{})})
+
+	problemErrorHandler(err, context)
+
+	problem := new(Problem)
+	json.Unmarshal(recorder.Body.Bytes(), problem)
+	return recorder, problem
+}
+
+func TestProblemErrorHandler_CoreError(t *testing.T) {
+	for err, status := range map[error]int{
+		core.ErrNotLobbyMember:          http.StatusForbidden,
+		core.ErrNotLobbyUser:            http.StatusForbidden,
+		core.ErrReservedTopic:           http.StatusForbidden,
+		core.ErrPlayerNotFound:          http.StatusNotFound,
+		core.ErrMessageNotFound:         http.StatusNotFound,
+		core.ErrValidation:              http.StatusUnprocessableEntity,
+		core.ErrLobbyServiceUnavailable: http.StatusServiceUnavailable,
+	} {
+		recorder, problem := handleTestError(mapError(fmt.Errorf("wrapped: %w", err)))
+
+		assert.Equal(t, status, recorder.Code)
+		assert.Equal(t, problem_content_type, recorder.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, status, problem.Status)
+		assert.Equal(t, "some-correlation-id", problem.CorrelationId)
+		assert.Equal(t, "/message/lobby/msg/1", problem.Instance)
+	}
+}
+
+func TestProblemErrorHandler_UnknownError(t *testing.T) {
+	recorder, problem := handleTestError(mapError(fmt.Errorf("some database error")))
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, problem_type_internal_error, problem.Type)
+	assert.Empty(t, problem.Detail)
+}
+
+func TestProblemErrorHandler_EchoError(t *testing.T) {
+	recorder, problem := handleTestError(echo.ErrNotFound)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, problem_type_unspecific, problem.Type)
+	assert.Equal(t, http.StatusText(http.StatusNotFound), problem.Title)
+}
+
+func TestMapRequestError_Validation(t *testing.T) {
+	assert.Equal(t, http.StatusUnprocessableEntity, mapRequestError(fmt.Errorf("could not validate message, %w", core.ErrValidation)).Code)
+	assert.Equal(t, http.StatusBadRequest, mapRequestError(fmt.Errorf("could not bind message")).Code)
+}
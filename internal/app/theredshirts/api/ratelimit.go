@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+const (
+	rate_limit_scope_player = "player"
+	rate_limit_scope_lobby  = "lobby"
+)
+
+// rate_limit_idle_time is the time after which a bucket is full again, unused buckets are dropped after it without changing any limit.
+const rate_limit_idle_time = time.Minute
+
+var (
+	rateLimitedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "message",
+		Name:      "rate_limited_total",
+		Help:      "Number of messages rejected because a rate limit was exceeded.",
+	}, []string{"scope"})
+)
+
+type (
+	// rateLimiter limits the messages per minute of every player and every lobby with token buckets. Topics with their own limit get their own buckets,
+	// all other topics share one bucket per player and lobby. A limit of 0 or less disables the limit.
+	rateLimiter struct {
+		player rateLimit
+		lobby  rateLimit
+
+		mutex     sync.Mutex
+		buckets   map[rateLimitKey]*rateLimitBucket
+		lastPrune time.Time
+	}
+
+	rateLimit struct {
+		perMinute      int
+		topicPerMinute map[string]int
+	}
+
+	rateLimitKey struct {
+		scope string
+		id    uuid.UUID
+		topic string
+	}
+
+	rateLimitBucket struct {
+		limiter  *rate.Limiter
+		lastUsed time.Time
+	}
+)
+
+func newRateLimiter() (*rateLimiter, error) {
+	player, err := loadRateLimit("RATE_LIMIT_PLAYER", 60)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading rate limit of players: %v", err)
+	}
+	lobby, err := loadRateLimit("RATE_LIMIT_LOBBY", 600)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading rate limit of lobbies: %v", err)
+	}
+	return &rateLimiter{player: *player, lobby: *lobby, buckets: make(map[rateLimitKey]*rateLimitBucket), lastPrune: time.Now()}, nil
+}
+
+func loadRateLimit(key string, fallback int) (*rateLimit, error) {
+	perMinute, err := util.GetEnvIntWithFallback(key, fallback)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading %s from environment variable: %v", key, err)
+	}
+	topicPerMinute, err := util.GetEnvIntMapWithFallback(key+"_TOPICS", map[string]int{})
+	if err != nil {
+		return nil, fmt.Errorf("error while loading %s_TOPICS from environment variable: %v", key, err)
+	}
+	return &rateLimit{perMinute: perMinute, topicPerMinute: topicPerMinute}, nil
+}
+
+// allow takes a token of the player and of the lobby. If one of them is exhausted, no token is taken and the time to wait is returned.
+func (limiter *rateLimiter) allow(playerId uuid.UUID, lobbyId uuid.UUID, topic string) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	now := time.Now()
+	limiter.prune(now)
+
+	var reservations []*rate.Reservation
+	var retryAfter time.Duration
+	allowed := true
+	for _, check := range []struct {
+		scope string
+		id    uuid.UUID
+		limit rateLimit
+	}{{rate_limit_scope_player, playerId, limiter.player}, {rate_limit_scope_lobby, lobbyId, limiter.lobby}} {
+		bucket := limiter.bucket(check.scope, check.id, topic, check.limit, now)
+		if bucket == nil {
+			continue
+		}
+		reservation := bucket.limiter.ReserveN(now, 1)
+		reservations = append(reservations, reservation)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			rateLimitedCounter.WithLabelValues(check.scope).Inc()
+			allowed = false
+			if delay > retryAfter {
+				retryAfter = delay
+			}
+		}
+	}
+
+	if !allowed {
+		for _, reservation := range reservations {
+			reservation.CancelAt(now)
+		}
+	}
+	return allowed, retryAfter
+}
+
+// bucket returns nil if the topic is not limited.
+func (limiter *rateLimiter) bucket(scope string, id uuid.UUID, topic string, limit rateLimit, now time.Time) *rateLimitBucket {
+	perMinute := limit.perMinute
+	if topicPerMinute, ok := limit.topicPerMinute[topic]; ok {
+		perMinute = topicPerMinute
+	} else {
+		topic = ""
+	}
+	if perMinute <= 0 {
+		return nil
+	}
+
+	key := rateLimitKey{scope: scope, id: id, topic: topic}
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = &rateLimitBucket{limiter: rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute)}
+		limiter.buckets[key] = bucket
+	}
+	bucket.lastUsed = now
+	return bucket
+}
+
+func (limiter *rateLimiter) prune(now time.Time) {
+	if now.Sub(limiter.lastPrune) < rate_limit_idle_time {
+		return
+	}
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.lastUsed) > rate_limit_idle_time {
+			delete(limiter.buckets, key)
+		}
+	}
+	limiter.lastPrune = now
+}
+
+// retryAfterSeconds rounds up, so a client waiting the given seconds gets a token.
+func retryAfterSeconds(retryAfter time.Duration) int {
+	return int(math.Ceil(retryAfter.Seconds()))
+}
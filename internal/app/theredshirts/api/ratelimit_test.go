@@ -0,0 +1,77 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRateLimiter(t *testing.T) *rateLimiter {
+	t.Setenv("RATE_LIMIT_PLAYER", "2")
+	t.Setenv("RATE_LIMIT_PLAYER_TOPICS", "GAME_STATE=-1,CHAT=1")
+	t.Setenv("RATE_LIMIT_LOBBY", "3")
+	limiter, err := newRateLimiter()
+	assert.Nil(t, err)
+	return limiter
+}
+
+func TestRateLimiterAllow_PlayerExceeded(t *testing.T) {
+	limiter := newTestRateLimiter(t)
+	somePlayerId := uuid.New()
+	someLobbyId := uuid.New()
+
+	allowed, _ := limiter.allow(somePlayerId, someLobbyId, "MOVE")
+	assert.True(t, allowed)
+	allowed, _ = limiter.allow(somePlayerId, someLobbyId, "MOVE")
+	assert.True(t, allowed)
+	allowed, retryAfter := limiter.allow(somePlayerId, someLobbyId, "MOVE")
+	assert.False(t, allowed)
+	assert.InDelta(t, 30*time.Second, retryAfter, float64(time.Second))
+	assert.Equal(t, 30, retryAfterSeconds(retryAfter))
+}
+
+func TestRateLimiterAllow_TopicLimits(t *testing.T) {
+	limiter := newTestRateLimiter(t)
+	somePlayerId := uuid.New()
+	someLobbyId := uuid.New()
+
+	allowed, _ := limiter.allow(somePlayerId, someLobbyId, "CHAT")
+	assert.True(t, allowed)
+	allowed, _ = limiter.allow(somePlayerId, someLobbyId, "CHAT")
+	assert.False(t, allowed)
+
+	allowed, _ = limiter.allow(somePlayerId, someLobbyId, "MOVE")
+	assert.True(t, allowed)
+	for i := 0; i < 5; i++ {
+		allowed, _ = limiter.allow(somePlayerId, uuid.New(), "GAME_STATE")
+		assert.True(t, allowed)
+	}
+}
+
+func TestRateLimiterAllow_LobbyExceededTakesNoPlayerToken(t *testing.T) {
+	limiter := newTestRateLimiter(t)
+	someLobbyId := uuid.New()
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.allow(uuid.New(), someLobbyId, "MOVE")
+		assert.True(t, allowed)
+	}
+	somePlayerId := uuid.New()
+
+	allowed, _ := limiter.allow(somePlayerId, someLobbyId, "MOVE")
+	assert.False(t, allowed)
+	allowed, _ = limiter.allow(somePlayerId, uuid.New(), "MOVE")
+	assert.True(t, allowed)
+	allowed, _ = limiter.allow(somePlayerId, uuid.New(), "MOVE")
+	assert.True(t, allowed)
+}
+
+func TestRateLimiterPrune_IdleBuckets(t *testing.T) {
+	limiter := newTestRateLimiter(t)
+	limiter.allow(uuid.New(), uuid.New(), "MOVE")
+	assert.Len(t, limiter.buckets, 2)
+
+	limiter.prune(time.Now().Add(2 * rate_limit_idle_time))
+	assert.Empty(t, limiter.buckets)
+}
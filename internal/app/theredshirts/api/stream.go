@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/core"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+const websocket_path = "/ws"
+const event_path = "/events"
+const last_event_id_header = "Last-Event-ID"
+const event_stream_content_type = "text/event-stream"
+
+// stream_refresh_interval makes sure messages are loaded from time to time even without notification, this keeps the player alive in the lobby.
+// The membership of the player is checked again with the same interval.
+const stream_refresh_interval = 30 * time.Second
+
+// pingCodec sends an empty ping frame, the browser answers it without the client having to do anything.
+var pingCodec = websocket.Codec{Marshal: func(v interface{}) ([]byte, byte, error) {
+	return nil, websocket.PingFrame, nil
+}}
+
+type (
+	messageStream interface {
+		send(message *Message) error
+		heartbeat() error
+	}
+
+	websocketStream struct {
+		connection *websocket.Conn
+	}
+
+	eventStream struct {
+		response *echo.Response
+	}
+)
+
+func (api *EchoApi) streamMessagesWebSocket(context echo.Context) error {
+	customContext := context.Get(context_key).(*util.Context)
+	logger := customContext.Logger
+	logger.Debug("Stream messages over websocket")
+
+	message, err := bindMessageGet(context)
+	if err != nil {
+		logger.Warnf("Error while binding get message: %v", err)
+		return mapRequestError(err)
+	}
+
+	playerId, err := getLobbyPlayerId(context, message.LobbyId)
+	if err != nil {
+		logger.Warnf("Error while authorizing player: %v", err)
+		return mapError(err)
+	}
+
+	if !api.startStream() {
+		logger.Warn("Stream refused because the server is shutting down")
+		return newProblem(http.StatusServiceUnavailable, problem_type_shutting_down, "The server is shutting down, try again later.")
+	}
+	defer api.streams.Done()
+	subscription, err := api.core.SubscribeMessages(customContext, playerId, message.LobbyId)
+	if err != nil {
+		logger.Warnf("Error while subscribing to messages: %v", err)
+		return mapError(err)
+	}
+	defer subscription.Close()
+
+	server := websocket.Server{Handler: func(connection *websocket.Conn) {
+		defer connection.Close()
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var ignored string
+			for {
+				if err := websocket.Message.Receive(connection, &ignored); err != nil {
+					return
+				}
+			}
+		}()
+
+		if err := api.streamMessages(customContext, subscription, message.Number, getPlayer(context).Expires, closed, &websocketStream{connection: connection}); err != nil {
+			logger.Warnf("Error while streaming messages over websocket: %v", err)
+		}
+	}}
+	server.ServeHTTP(context.Response(), context.Request())
+	return nil
+}
+
+func (api *EchoApi) streamMessagesEvents(context echo.Context) error {
+	customContext := context.Get(context_key).(*util.Context)
+	logger := customContext.Logger
+	logger.Debug("Stream messages as server-sent events")
+
+	message, err := bindMessageGet(context)
+	if err != nil {
+		logger.Warnf("Error while binding get message: %v", err)
+		return mapRequestError(err)
+	}
+
+	number, err := getLastEventId(context, message.Number)
+	if err != nil {
+		logger.Warnf("Error while binding last event id: %v", err)
+		return mapRequestError(err)
+	}
+
+	playerId, err := getLobbyPlayerId(context, message.LobbyId)
+	if err != nil {
+		logger.Warnf("Error while authorizing player: %v", err)
+		return mapError(err)
+	}
+
+	if !api.startStream() {
+		logger.Warn("Stream refused because the server is shutting down")
+		return newProblem(http.StatusServiceUnavailable, problem_type_shutting_down, "The server is shutting down, try again later.")
+	}
+	defer api.streams.Done()
+	subscription, err := api.core.SubscribeMessages(customContext, playerId, message.LobbyId)
+	if err != nil {
+		logger.Warnf("Error while subscribing to messages: %v", err)
+		return mapError(err)
+	}
+	defer subscription.Close()
+
+	response := context.Response()
+	response.Header().Set(echo.HeaderContentType, event_stream_content_type)
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("X-Accel-Buffering", "no")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+
+	if err := api.streamMessages(customContext, subscription, number, getPlayer(context).Expires, context.Request().Context().Done(), &eventStream{response: response}); err != nil {
+		logger.Warnf("Error while streaming messages as server-sent events: %v", err)
+	}
+	return nil
+}
+
+// getLastEventId returns the number of the Last-Event-ID header which is set by browsers when they reconnect, otherwise the given number.
+func getLastEventId(context echo.Context, number int) (int, error) {
+	lastEventId := context.Request().Header.Get(last_event_id_header)
+	if lastEventId == "" {
+		return number, nil
+	}
+	number, err := strconv.Atoi(lastEventId)
+	if err != nil {
+		return 0, fmt.Errorf("error while parsing last event id: %v", err)
+	}
+	return number, nil
+}
+
+// streamMessages sends every message after number in order of their number until closed is closed, the server shuts down or sending fails.
+// The stream also ends when the token of the player expires or the player is no longer in the lobby.
+func (api *EchoApi) streamMessages(context *util.Context, subscription core.Subscription, number int, expires time.Time, closed <-chan struct{}, stream messageStream) error {
+	ticker := time.NewTicker(api.streamInterval)
+	defer ticker.Stop()
+	var expired <-chan time.Time
+	if !expires.IsZero() {
+		timer := time.NewTimer(time.Until(expires))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		messages, err := subscription.GetMessages(context, number)
+		if err != nil {
+			return fmt.Errorf("error while loading messages: %v", err)
+		}
+		for _, message := range messages {
+			if err := stream.send(mapToMessage(message)); err != nil {
+				return fmt.Errorf("error while sending message %v: %v", message.ID, err)
+			}
+			number = message.Number
+		}
+
+		select {
+		case <-closed:
+			return nil
+		case <-api.shutdown:
+			return nil
+		case <-expired:
+			context.Logger.Debug("Stream closed because the token expired")
+			return nil
+		case <-subscription.Notifications():
+		case <-ticker.C:
+			if err := subscription.Refresh(context); err != nil {
+				return fmt.Errorf("error while checking player in lobby: %v", err)
+			}
+			if err := stream.heartbeat(); err != nil {
+				return fmt.Errorf("error while sending heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+func (stream *websocketStream) send(message *Message) error {
+	return websocket.JSON.Send(stream.connection, message)
+}
+
+// heartbeat sends a ping, so proxies do not close the idle connection.
+func (stream *websocketStream) heartbeat() error {
+	return pingCodec.Send(stream.connection, nil)
+}
+
+func (stream *eventStream) send(message *Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("error while marshalling message: %v", err)
+	}
+	if _, err := fmt.Fprintf(stream.response, "id: %d\nevent: message\ndata: %s\n\n", message.Number, data); err != nil {
+		return err
+	}
+	stream.response.Flush()
+	return nil
+}
+
+// heartbeat sends a comment, so proxies do not close the idle connection.
+func (stream *eventStream) heartbeat() error {
+	if _, err := fmt.Fprint(stream.response, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	stream.response.Flush()
+	return nil
+}
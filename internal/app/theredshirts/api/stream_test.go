@@ -0,0 +1,309 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/core"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/go-playground/validator"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+type (
+	streamTestCore struct {
+		core.Core
+		subscription *testSubscription
+	}
+
+	testSubscription struct {
+		lock          sync.Mutex
+		messages      []*core.Message
+		notifications chan struct{}
+		refreshErr    error
+		refreshes     int
+	}
+
+	// recordingConn keeps every byte read from the connection, so the frames sent by the server can be checked.
+	recordingConn struct {
+		net.Conn
+		lock sync.Mutex
+		read bytes.Buffer
+	}
+)
+
+func (core *streamTestCore) SubscribeMessages(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID) (core.Subscription, error) {
+	return core.subscription, nil
+}
+
+func newTestSubscription(count int) *testSubscription {
+	subscription := &testSubscription{notifications: make(chan struct{}, 1)}
+	for number := 1; number <= count; number++ {
+		subscription.add()
+	}
+	return subscription
+}
+
+func (subscription *testSubscription) add() {
+	subscription.lock.Lock()
+	defer subscription.lock.Unlock()
+	number := len(subscription.messages) + 1
+	subscription.messages = append(subscription.messages, &core.Message{ID: uuid.New(), Number: number, Topic: "CHAT", Message: map[string]interface{}{"text": "hello"}})
+	select {
+	case subscription.notifications <- struct{}{}:
+	default:
+	}
+}
+
+func (subscription *testSubscription) Notifications() <-chan struct{} {
+	return subscription.notifications
+}
+
+func (subscription *testSubscription) GetMessages(context *util.Context, number int) ([]*core.Message, error) {
+	subscription.lock.Lock()
+	defer subscription.lock.Unlock()
+	var messages []*core.Message
+	for _, message := range subscription.messages {
+		if message.Number > number {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (subscription *testSubscription) Refresh(context *util.Context) error {
+	subscription.lock.Lock()
+	defer subscription.lock.Unlock()
+	subscription.refreshes++
+	return subscription.refreshErr
+}
+
+func (subscription *testSubscription) Close() {
+}
+
+func (subscription *testSubscription) getRefreshes() int {
+	subscription.lock.Lock()
+	defer subscription.lock.Unlock()
+	return subscription.refreshes
+}
+
+func (conn *recordingConn) Read(b []byte) (int, error) {
+	n, err := conn.Conn.Read(b)
+	conn.lock.Lock()
+	conn.read.Write(b[:n])
+	conn.lock.Unlock()
+	return n, err
+}
+
+func (conn *recordingConn) contains(frame []byte) bool {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	return bytes.Contains(conn.read.Bytes(), frame)
+}
+
+func newStreamTestServer(t *testing.T, subscription *testSubscription, player *authenticatedPlayer, interval time.Duration) (*EchoApi, *httptest.Server) {
+	api := &EchoApi{core: &streamTestCore{subscription: subscription}, shutdown: make(chan struct{}), streamInterval: interval}
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+	e.HTTPErrorHandler = problemErrorHandler
+	group := e.Group(message_root_path, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(context_key, &util.Context{Logger: log.WithFields(log.Fields{}), Request: c.Request().Context()})
+			c.Set(player_key, player)
+			return next(c)
+		}
+	})
+	initChatInterface(group, api)
+	server := httptest.NewServer(e)
+	t.Cleanup(func() {
+		api.closeStreams()
+		server.Close()
+	})
+	return api, server
+}
+
+func openEventStream(t *testing.T, server *httptest.Server, lastEventId string) *http.Response {
+	request, err := http.NewRequest(http.MethodGet, server.URL+message_root_path+"/"+uuid.NewString()+event_path+"/1", nil)
+	assert.Nil(t, err)
+	if lastEventId != "" {
+		request.Header.Set(last_event_id_header, lastEventId)
+	}
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	t.Cleanup(func() { response.Body.Close() })
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, event_stream_content_type, response.Header.Get(echo.HeaderContentType))
+	return response
+}
+
+// readEvent reads the lines of the next event up to the blank line ending it.
+func readEvent(t *testing.T, reader *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func assertClosed(t *testing.T, body io.Reader) {
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, body)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not closed")
+	}
+}
+
+func openWebSocket(t *testing.T, server *httptest.Server) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + message_root_path + "/" + uuid.NewString() + websocket_path + "/1"
+	connection, err := websocket.Dial(url, "", server.URL)
+	assert.Nil(t, err)
+	t.Cleanup(func() { connection.Close() })
+	return connection
+}
+
+func TestStreamMessagesEvents_Framing(t *testing.T) {
+	subscription := newTestSubscription(2)
+	_, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New()}, time.Minute)
+
+	reader := bufio.NewReader(openEventStream(t, server, "").Body)
+
+	event := readEvent(t, reader)
+	assert.Len(t, event, 3)
+	assert.Equal(t, "id: 2", event[0])
+	assert.Equal(t, "event: message", event[1])
+	assert.True(t, strings.HasPrefix(event[2], "data: {"))
+	assert.Contains(t, event[2], subscription.messages[1].ID.String())
+
+	subscription.add()
+	assert.Equal(t, "id: 3", readEvent(t, reader)[0])
+}
+
+func TestStreamMessagesEvents_LastEventIdResumed(t *testing.T) {
+	subscription := newTestSubscription(4)
+	_, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New()}, time.Minute)
+
+	reader := bufio.NewReader(openEventStream(t, server, "3").Body)
+
+	assert.Equal(t, "id: 4", readEvent(t, reader)[0])
+}
+
+func TestStreamMessagesEvents_Heartbeat(t *testing.T) {
+	subscription := newTestSubscription(1)
+	_, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New()}, 10*time.Millisecond)
+
+	reader := bufio.NewReader(openEventStream(t, server, "").Body)
+
+	assert.Equal(t, []string{": heartbeat"}, readEvent(t, reader))
+	assert.GreaterOrEqual(t, subscription.getRefreshes(), 1)
+}
+
+func TestStreamMessagesEvents_ShutdownClosed(t *testing.T) {
+	subscription := newTestSubscription(2)
+	api, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New()}, time.Minute)
+	response := openEventStream(t, server, "")
+	reader := bufio.NewReader(response.Body)
+	readEvent(t, reader)
+
+	api.closeStreams()
+
+	assertClosed(t, reader)
+}
+
+func TestStreamMessagesEvents_NotLobbyMemberClosed(t *testing.T) {
+	subscription := newTestSubscription(1)
+	subscription.refreshErr = core.ErrNotLobbyMember
+	_, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New()}, 10*time.Millisecond)
+
+	assertClosed(t, openEventStream(t, server, "").Body)
+}
+
+func TestStreamMessagesEvents_TokenExpiredClosed(t *testing.T) {
+	subscription := newTestSubscription(1)
+	_, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New(), Expires: time.Now().Add(50 * time.Millisecond)}, time.Minute)
+
+	assertClosed(t, openEventStream(t, server, "").Body)
+}
+
+func TestStreamMessagesWebSocket_Received(t *testing.T) {
+	subscription := newTestSubscription(2)
+	_, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New()}, time.Minute)
+	connection := openWebSocket(t, server)
+
+	var message Message
+	assert.Nil(t, websocket.JSON.Receive(connection, &message))
+	assert.Equal(t, subscription.messages[1].ID, message.ID)
+	assert.Equal(t, 2, message.Number)
+
+	subscription.add()
+	assert.Nil(t, websocket.JSON.Receive(connection, &message))
+	assert.Equal(t, 3, message.Number)
+}
+
+func TestStreamMessagesWebSocket_Ping(t *testing.T) {
+	subscription := newTestSubscription(1)
+	_, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New()}, 10*time.Millisecond)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + message_root_path + "/" + uuid.NewString() + websocket_path + "/1"
+	config, err := websocket.NewConfig(url, server.URL)
+	assert.Nil(t, err)
+	tcpConnection, err := net.Dial("tcp", config.Location.Host)
+	assert.Nil(t, err)
+	recording := &recordingConn{Conn: tcpConnection}
+	connection, err := websocket.NewClient(config, recording)
+	assert.Nil(t, err)
+	defer connection.Close()
+
+	for subscription.getRefreshes() < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	subscription.add()
+	var message Message
+	assert.Nil(t, websocket.JSON.Receive(connection, &message))
+
+	assert.True(t, recording.contains([]byte{0x80 | websocket.PingFrame, 0x00}))
+}
+
+func TestStreamMessagesWebSocket_ShutdownClosed(t *testing.T) {
+	subscription := newTestSubscription(2)
+	api, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New()}, time.Minute)
+	connection := openWebSocket(t, server)
+	var message Message
+	assert.Nil(t, websocket.JSON.Receive(connection, &message))
+
+	api.closeStreams()
+
+	err := websocket.JSON.Receive(connection, &message)
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func TestStreamMessagesWebSocket_TokenExpiredClosed(t *testing.T) {
+	subscription := newTestSubscription(1)
+	_, server := newStreamTestServer(t, subscription, &authenticatedPlayer{PlayerId: uuid.New(), Expires: time.Now().Add(50 * time.Millisecond)}, time.Minute)
+	connection := openWebSocket(t, server)
+
+	var message Message
+	err := websocket.JSON.Receive(connection, &message)
+	assert.True(t, errors.Is(err, io.EOF))
+}
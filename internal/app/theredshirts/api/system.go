@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	lobby_timestamp_header = "X-Lobby-Timestamp"
+	lobby_signature_header = "X-Lobby-Signature"
+	// max_signed_body_size limits the body read to verify a signature, anyone can send the signature header
+	max_signed_body_size = 64 << 10
+)
+
+type (
+	// systemAuthenticator grants the identity of the lobby user to requests of the lobby service. The lobby service either signs its requests
+	// with a shared secret or sends a client certificate with the configured name.
+	systemAuthenticator struct {
+		lobbyUserId uuid.UUID
+		// secret is nil if signatures are disabled
+		secret []byte
+		maxAge time.Duration
+		// certificateName is empty if client certificates are disabled
+		certificateName string
+	}
+)
+
+func newSystemAuthenticator(clientCertificates bool) (*systemAuthenticator, error) {
+	lobbyUserId, err := util.GetEnvUUID("LOBBY_USER")
+	if err != nil {
+		return nil, fmt.Errorf("error while loading lobby user env: %v", err)
+	}
+	maxAge, err := util.GetEnvIntWithFallback("LOBBY_SIGNATURE_MAX_AGE", 300)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading max age of lobby signatures from environment variable: %v", err)
+	}
+	authenticator := &systemAuthenticator{lobbyUserId: lobbyUserId, maxAge: time.Duration(maxAge) * time.Second}
+	if secret := util.GetEnvWithFallback("LOBBY_HMAC_SECRET", ""); secret != "" {
+		authenticator.secret = []byte(secret)
+	}
+	authenticator.certificateName = util.GetEnvWithFallback("LOBBY_CLIENT_CERT_NAME", "")
+	if authenticator.certificateName != "" && !clientCertificates {
+		return nil, errors.New("lobby client certificate name is set but client certificates are not verified, set TLS_CLIENT_CA_FILE")
+	}
+	if authenticator.secret == nil && authenticator.certificateName == "" {
+		log.Warn("Neither LOBBY_HMAC_SECRET nor LOBBY_CLIENT_CERT_NAME is set, the lobby user can not authenticate")
+	}
+	return authenticator, nil
+}
+
+// authenticate returns true if the request is sent by the lobby service and an error if the request carries credentials of the lobby service which are not valid.
+func (authenticator *systemAuthenticator) authenticate(request *http.Request) (bool, error) {
+	if authenticator.certificateName != "" && authenticator.hasCertificate(request) {
+		return true, nil
+	}
+	if request.Header.Get(lobby_signature_header) == "" {
+		return false, nil
+	}
+	if authenticator.secret == nil {
+		return false, errors.New("request is signed but signatures are not enabled")
+	}
+	if err := authenticator.verifySignature(request); err != nil {
+		return false, fmt.Errorf("signature of request is not valid: %v", err)
+	}
+	return true, nil
+}
+
+func (authenticator *systemAuthenticator) hasCertificate(request *http.Request) bool {
+	if request.TLS == nil {
+		return false
+	}
+	for _, chain := range request.TLS.VerifiedChains {
+		if len(chain) == 0 {
+			continue
+		}
+		certificate := chain[0]
+		if certificate.Subject.CommonName == authenticator.certificateName {
+			return true
+		}
+		for _, name := range certificate.DNSNames {
+			if name == authenticator.certificateName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (authenticator *systemAuthenticator) verifySignature(request *http.Request) error {
+	if request.ContentLength > max_signed_body_size {
+		return fmt.Errorf("body of %d bytes is larger than %d bytes", request.ContentLength, max_signed_body_size)
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, request.Body, max_signed_body_size))
+	if err != nil {
+		return fmt.Errorf("error while reading body: %v", err)
+	}
+	request.Body.Close()
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	timestamp := request.Header.Get(lobby_timestamp_header)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("error while parsing timestamp: %v", err)
+	}
+	age := time.Since(time.Unix(seconds, 0))
+	if age > authenticator.maxAge || age < -authenticator.maxAge {
+		return fmt.Errorf("timestamp %s is older than %v or in the future", timestamp, authenticator.maxAge)
+	}
+	signature, err := hex.DecodeString(request.Header.Get(lobby_signature_header))
+	if err != nil {
+		return fmt.Errorf("error while decoding signature: %v", err)
+	}
+
+	if !hmac.Equal(signature, signRequest(authenticator.secret, request.Method, request.URL.RequestURI(), timestamp, body)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// signRequest signs the method, the path with query, the timestamp and the hash of the body separated by new lines with HMAC-SHA256.
+func signRequest(secret []byte, method string, requestUri string, timestamp string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, requestUri, timestamp, hex.EncodeToString(bodyHash[:]))
+	return mac.Sum(nil)
+}
+
+// loadTLSConfig returns nil if no certificate is configured, the server serves plain http then.
+// With a client CA the certificates of clients are verified if they send one, only the lobby service has to.
+func loadTLSConfig() (*tls.Config, error) {
+	certFile := util.GetEnvWithFallback("TLS_CERT_FILE", "")
+	keyFile := util.GetEnvWithFallback("TLS_KEY_FILE", "")
+	clientCaFile := util.GetEnvWithFallback("TLS_CLIENT_CA_FILE", "")
+	if certFile == "" && keyFile == "" {
+		if clientCaFile != "" {
+			return nil, errors.New("client CA is set without TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading certificate: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	if clientCaFile == "" {
+		return config, nil
+	}
+
+	clientCa, err := os.ReadFile(clientCaFile)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading client CA from %s: %v", clientCaFile, err)
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(clientCa) {
+		return nil, fmt.Errorf("no certificate found in client CA %s", clientCaFile)
+	}
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return config, nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	test_lobby_secret    = "some-lobby-secret"
+	test_lobby_cert_name = "theredshirts-lobby"
+)
+
+func newTestSystemAuthenticator() *systemAuthenticator {
+	return &systemAuthenticator{lobbyUserId: uuid.New(), secret: []byte(test_lobby_secret), maxAge: time.Minute, certificateName: test_lobby_cert_name}
+}
+
+func newSignedTestRequest(body string, timestamp time.Time, secret string) *http.Request {
+	request := httptest.NewRequest(http.MethodPut, "/message/some-lobby/msg/some-message", strings.NewReader(body))
+	seconds := strconv.FormatInt(timestamp.Unix(), 10)
+	request.Header.Set(lobby_timestamp_header, seconds)
+	request.Header.Set(lobby_signature_header, hex.EncodeToString(signRequest([]byte(secret), request.Method, request.URL.RequestURI(), seconds, []byte(body))))
+	return request
+}
+
+func TestSystemAuthenticator_Signature(t *testing.T) {
+	request := newSignedTestRequest(`{"topic":"CHAT"}`, time.Now(), test_lobby_secret)
+
+	system, err := newTestSystemAuthenticator().authenticate(request)
+	assert.Nil(t, err)
+	assert.True(t, system)
+	body, err := io.ReadAll(request.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"topic":"CHAT"}`, string(body))
+}
+
+func TestSystemAuthenticator_SignatureRejected(t *testing.T) {
+	tamperedRequest := newSignedTestRequest(`{"topic":"CHAT"}`, time.Now(), test_lobby_secret)
+	tamperedRequest.Body = io.NopCloser(strings.NewReader(`{"topic":"PLAYER_JOINS_LOBBY"}`))
+	unknownLengthRequest := newSignedTestRequest(strings.Repeat("x", max_signed_body_size+1), time.Now(), test_lobby_secret)
+	unknownLengthRequest.ContentLength = -1
+	for name, request := range map[string]*http.Request{
+		"wrong secret": newSignedTestRequest("", time.Now(), "other-secret"),
+		"too old":      newSignedTestRequest("", time.Now().Add(-2*time.Minute), test_lobby_secret),
+		"tampered":     tamperedRequest,
+		"too large":    newSignedTestRequest(strings.Repeat("x", max_signed_body_size+1), time.Now(), test_lobby_secret),
+		"too long":     unknownLengthRequest,
+	} {
+		t.Run(name, func(t *testing.T) {
+			system, err := newTestSystemAuthenticator().authenticate(request)
+			assert.NotNil(t, err)
+			assert.False(t, system)
+		})
+	}
+}
+
+func TestSystemAuthenticator_Certificate(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/message", nil)
+	request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "some-player"}, DNSNames: []string{test_lobby_cert_name}}}}}
+
+	system, err := newTestSystemAuthenticator().authenticate(request)
+	assert.Nil(t, err)
+	assert.True(t, system)
+}
+
+func TestSystemAuthenticator_NoCredentials(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/message", nil)
+	request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "some-player"}}}}}
+
+	system, err := newTestSystemAuthenticator().authenticate(request)
+	assert.Nil(t, err)
+	assert.False(t, system)
+}
+
+func TestAuthMiddleware_LobbyUserWithoutSystemAuthentication(t *testing.T) {
+	systemAuthenticator := newTestSystemAuthenticator()
+	request := httptest.NewRequest(http.MethodGet, "/message", nil)
+	request.Header.Set(player_id_param, systemAuthenticator.lobbyUserId.String())
+	context := echo.New().NewContext(request, httptest.NewRecorder())
+	context.Set(context_key, &util.Context{Logger: log.WithFields(log.Fields{})})
+
+	err := authMiddleware(&headerAuthenticator{}, systemAuthenticator)(func(c echo.Context) error {
+		return nil
+	})(context)
+	assert.Equal(t, http.StatusUnauthorized, mapToProblem(err).Status)
+}
+
+func TestAuthMiddleware_LobbyService(t *testing.T) {
+	systemAuthenticator := newTestSystemAuthenticator()
+	context := echo.New().NewContext(newSignedTestRequest("", time.Now(), test_lobby_secret), httptest.NewRecorder())
+	context.Set(context_key, &util.Context{Logger: log.WithFields(log.Fields{})})
+
+	err := authMiddleware(&headerAuthenticator{}, systemAuthenticator)(func(c echo.Context) error {
+		assert.Equal(t, &authenticatedPlayer{PlayerId: systemAuthenticator.lobbyUserId, System: true}, getPlayer(c))
+		return nil
+	})(context)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+)
+
+// GetLeader is only answered for the lobby user.
+func (core CoreFacade) GetLeader(context *util.Context, requesterId uuid.UUID) (*Leader, error) {
+	context.Logger.Debug("Get leader")
+	if requesterId != core.lobbyPlayerId {
+		return nil, ErrNotLobbyUser
+	}
+	leaderInstanceId, err := core.leader.lock.Leader()
+	if err != nil {
+		return nil, fmt.Errorf("error while loading leader: %v", err)
+	}
+	return &Leader{InstanceId: core.leader.instanceId, Leader: core.leader.isLeader(), LeaderInstanceId: leaderInstanceId}, nil
+}
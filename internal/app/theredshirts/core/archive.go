@@ -0,0 +1,123 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/db"
+	"github.com/google/uuid"
+)
+
+// archive_batch_size limits the messages held in memory while archiving
+const archive_batch_size = 1000
+
+type (
+	// messageDeletion selects messages to delete, load is only used if an archive is configured.
+	messageDeletion struct {
+		load   func(tx db.DBTx, limit int) ([]*db.Message, error)
+		delete func(tx db.DBTx) (int64, error)
+	}
+)
+
+// deleteMessages deletes the messages of all deletions in one transaction if no archive is configured. Otherwise the messages are loaded in
+// batches and archived without holding a transaction, afterwards exactly the archived messages are deleted. The deletion is not atomic then,
+// a failure leaves the batches deleted before. Messages which were archived but could not be deleted are archived again by the next deletion,
+// which replaces their archive file.
+func (core CoreFacade) deleteMessages(deletions ...*messageDeletion) (int64, error) {
+	if core.archive == nil {
+		tx, err := core.db.StartTransaction()
+		if err != nil {
+			return 0, fmt.Errorf("something went wrong while creating transaction: %v", err)
+		}
+		defer tx.Rollback()
+		var deleted int64
+		for _, deletion := range deletions {
+			count, err := deletion.delete(tx)
+			if err != nil {
+				return 0, err
+			}
+			deleted += count
+		}
+		return deleted, tx.Commit()
+	}
+
+	var deleted int64
+	for _, deletion := range deletions {
+		for {
+			messages, revisions, err := core.loadMessagesToArchive(deletion)
+			if err != nil {
+				return deleted, err
+			}
+			if len(messages) == 0 {
+				break
+			}
+			if err := core.archive.Archive(mapToArchivedMessages(messages, revisions)); err != nil {
+				return deleted, fmt.Errorf("error while archiving messages: %v", err)
+			}
+			count, err := core.deleteArchivedMessages(messages)
+			if err != nil {
+				return deleted, err
+			}
+			deleted += count
+			if len(messages) < archive_batch_size || count == 0 {
+				break
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// loadMessagesToArchive loads a batch of messages together with the revisions of the edited ones, which are deleted with the messages.
+func (core CoreFacade) loadMessagesToArchive(deletion *messageDeletion) ([]*db.Message, []*db.MessageRevision, error) {
+	tx, err := core.db.StartTransaction()
+	if err != nil {
+		return nil, nil, fmt.Errorf("something went wrong while creating transaction: %v", err)
+	}
+	defer tx.Rollback()
+	messages, err := deletion.load(tx, archive_batch_size)
+	if err != nil {
+		return nil, nil, err
+	}
+	var editedIds []uuid.UUID
+	for _, message := range messages {
+		if message.Revision > 1 {
+			editedIds = append(editedIds, message.ID)
+		}
+	}
+	var revisions []*db.MessageRevision
+	if len(editedIds) > 0 {
+		if revisions, err = tx.GetMessageRevisions(editedIds); err != nil {
+			return nil, nil, err
+		}
+	}
+	return messages, revisions, tx.Commit()
+}
+
+func (core CoreFacade) deleteArchivedMessages(messages []*db.Message) (int64, error) {
+	tx, err := core.db.StartTransaction()
+	if err != nil {
+		return 0, fmt.Errorf("something went wrong while creating transaction: %v", err)
+	}
+	defer tx.Rollback()
+	messageIds := make([]uuid.UUID, len(messages))
+	for index, message := range messages {
+		messageIds[index] = message.ID
+	}
+	deleted, err := tx.DeleteMessagesById(messageIds)
+	if err != nil {
+		return 0, err
+	}
+	return deleted, tx.Commit()
+}
+
+func mapToArchivedMessages(dbMessages []*db.Message, dbRevisions []*db.MessageRevision) []*adapter.ArchivedMessage {
+	revisions := make(map[uuid.UUID][]*adapter.ArchivedRevision)
+	for _, revision := range dbRevisions {
+		revisions[revision.MessageId] = append(revisions[revision.MessageId], &adapter.ArchivedRevision{Revision: revision.Revision, Number: revision.Number, Message: revision.Message})
+	}
+	messages := make([]*adapter.ArchivedMessage, len(dbMessages))
+	for index, message := range dbMessages {
+		messages[index] = &adapter.ArchivedMessage{ID: message.ID, SendTime: message.SendTime, LobbyId: message.LobbyId, PlayerId: message.PlayerId, Number: message.Number, Topic: message.Topic, Message: message.Message, Direct: message.Direct, Channel: message.Channel, Team: message.Team, Revision: message.Revision, Revisions: revisions[message.ID]}
+	}
+	return messages
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type testArchive struct {
+	messages []*adapter.ArchivedMessage
+	err      error
+}
+
+func (archive *testArchive) Archive(messages []*adapter.ArchivedMessage) error {
+	if archive.err != nil {
+		return archive.err
+	}
+	archive.messages = append(archive.messages, messages...)
+	return nil
+}
+
+func TestDeleteLobbyMessages_Archived(t *testing.T) {
+	core := newTestCore(t)
+	archive := &testArchive{}
+	core.archive = archive
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, newTestMessage(someLobbyId, somePlayerId, "CHAT")))
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, newTestMessage(someLobbyId, somePlayerId, "CHAT")))
+
+	assert.Nil(t, core.DeleteLobbyMessages(newTestContext(), core.lobbyPlayerId, someLobbyId))
+	assert.Len(t, archive.messages, 2)
+	messages, err := core.GetMessages(newTestContext(), core.newPlayer(someLobbyId), someLobbyId, 0, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestDeleteLobbyMessages_ArchiveFailed(t *testing.T) {
+	core := newTestCore(t)
+	core.archive = &testArchive{err: errors.New("disk full")}
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, newTestMessage(someLobbyId, somePlayerId, "CHAT")))
+
+	assert.NotNil(t, core.DeleteLobbyMessages(newTestContext(), core.lobbyPlayerId, someLobbyId))
+	messages, err := core.GetMessages(newTestContext(), core.newPlayer(someLobbyId), someLobbyId, 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+}
+
+func TestDeleteLobbyMessages_ArchivedRevisions(t *testing.T) {
+	core := newTestCore(t)
+	archive := &testArchive{}
+	core.archive = archive
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	message := newTestMessage(someLobbyId, somePlayerId, "CHAT")
+	message.Message = map[string]interface{}{"text": "first"}
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, message))
+	assert.Nil(t, core.EditMessage(newTestContext(), somePlayerId, &Message{ID: message.ID, LobbyId: someLobbyId, Message: map[string]interface{}{"text": "second"}}))
+
+	assert.Nil(t, core.DeleteLobbyMessages(newTestContext(), core.lobbyPlayerId, someLobbyId))
+	assert.Len(t, archive.messages, 1)
+	assert.Equal(t, 2, archive.messages[0].Revision)
+	assert.Equal(t, "second", archive.messages[0].Message["text"])
+	assert.Len(t, archive.messages[0].Revisions, 1)
+	assert.Equal(t, 1, archive.messages[0].Revisions[0].Revision)
+	assert.Equal(t, 1, archive.messages[0].Revisions[0].Number)
+	assert.Equal(t, "first", archive.messages[0].Revisions[0].Message["text"])
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+)
+
+const (
+	channel_lobby      = "lobby"
+	channel_team       = "team"
+	channel_spectators = "spectators"
+)
+
+// resolveChannel sets the team of the message from its channel. Every player gets the messages of the lobby channel,
+// messages of the team channel only go to the team of the player and messages of the spectators channel only to the spectators.
+// The player is loaded from the lobby service if it is nil.
+func (core CoreFacade) resolveChannel(context *util.Context, message *Message, player *adapter.SimplePlayer) error {
+	switch message.Channel {
+	case "", channel_lobby:
+		message.Channel = channel_lobby
+		message.Team = ""
+	case channel_spectators:
+		message.Team = core.spectatorTeam
+	case channel_team:
+		if player == nil {
+			var err error
+			if player, err = core.playerDirectory.GetPlayer(context, message.PlayerId); err != nil {
+				return fmt.Errorf("error while getting player %v: %w", message.PlayerId, err)
+			}
+		}
+		if player.Team == "" {
+			return fmt.Errorf("%w: player %v has no team", ErrValidation, message.PlayerId)
+		}
+		message.Team = player.Team
+	default:
+		return fmt.Errorf("%w: channel %s is unknown", ErrValidation, message.Channel)
+	}
+	return nil
+}
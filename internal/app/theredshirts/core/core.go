@@ -3,11 +3,13 @@ package core
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
 	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/db"
 	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/go-co-op/gocron"
 	"github.com/google/uuid"
 )
 
@@ -15,15 +17,41 @@ type (
 
 	//Facade
 	CoreFacade struct {
-		db            db.DB
-		lobbyAdapter  *adapter.LobbyAdapter
-		lobbyPlayerId uuid.UUID
+		db              db.DB
+		playerDirectory adapter.PlayerDirectory
+		playerCache     *adapter.PlayerCache
+		refresher       *playerRefresher
+		lobbyPlayerId   uuid.UUID
+		notifier        *lobbyNotifier
+		maxWait         time.Duration
+		scheduler       *gocron.Scheduler
+		leader          *leaderElection
+		archive         adapter.MessageArchive
+		topics          *topicRegistry
+		reservedTopics  map[string]bool
+		spectatorTeam   string
+		closing         chan struct{}
+		closeOnce       *sync.Once
 	}
 
 	Core interface {
 		//Message
-		CreateMessage(context *util.Context, message *Message) error
-		GetMessages(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID, number int) ([]*Message, error)
+		CreateMessage(context *util.Context, requesterId uuid.UUID, message *Message) error
+		EditMessage(context *util.Context, requesterId uuid.UUID, message *Message) error
+		GetMessageTopic(context *util.Context, requesterId uuid.UUID, lobbyId uuid.UUID, messageId uuid.UUID) (string, error)
+		GetMessages(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID, number int, wait time.Duration) ([]*Message, error)
+		SubscribeMessages(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID) (Subscription, error)
+		DeleteLobbyMessages(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID) error
+		CheckLobbyMember(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID) error
+		//Player
+		InvalidatePlayer(context *util.Context, requesterId uuid.UUID, playerId uuid.UUID) error
+		//Admin
+		GetLeader(context *util.Context, requesterId uuid.UUID) (*Leader, error)
+		//Health
+		CheckHealth(context *util.Context) map[string]error
+		//Lifecycle
+		Shutdown()
+		Close()
 	}
 
 	//Objects
@@ -35,6 +63,21 @@ type (
 		Number   int
 		Topic    string
 		Message  map[string]interface{}
+		// Direct messages are only returned to their recipients
+		Direct bool
+		// Recipients make the message direct when creating it, they are not loaded with the messages
+		Recipients []uuid.UUID
+		// Channel is lobby, team or spectators, the team of the audience is resolved from it when creating the message
+		Channel string
+		Team    string
+		// Revision counts the contents of the message, it is 1 until the message is edited
+		Revision int
+	}
+
+	Leader struct {
+		InstanceId       string
+		Leader           bool
+		LeaderInstanceId string
 	}
 
 	Player struct {
@@ -44,8 +87,23 @@ type (
 	}
 )
 
+const (
+	player_joins_lobby_topic = "PLAYER_JOINS_LOBBY"
+)
+
 var (
 	ErrWrongLobbyPassword = errors.New("wrong password")
+	ErrNotLobbyUser       = errors.New("only the lobby user is allowed to do this")
+	ErrNotLobbyMember     = errors.New("player is not a member of the lobby")
+	ErrReservedTopic      = errors.New("topic is reserved for the lobby user")
+	// ErrValidation is returned if a request is well formed but its content is not accepted
+	ErrValidation = errors.New("validation failed")
+	// ErrPlayerNotFound is returned if the player is unknown to the lobby service
+	ErrPlayerNotFound = adapter.ErrPlayerNotFound
+	// ErrLobbyServiceUnavailable is returned while requests to the lobby service fail
+	ErrLobbyServiceUnavailable = adapter.ErrLobbyServiceUnavailable
+	// ErrMessageNotFound is returned if the message does not exist in the lobby
+	ErrMessageNotFound = db.ErrMessageNotFound
 )
 
 func NewCore() (Core, error) {
@@ -53,12 +111,66 @@ func NewCore() (Core, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error while initializing database: %v", err)
 	}
-	lobbyAdapter := adapter.NewLobbyAdapter()
+	playerDirectory, err := adapter.NewPlayerDirectory()
+	if err != nil {
+		return nil, fmt.Errorf("error while initializing player directory: %v", err)
+	}
+	playerCache, err := adapter.NewPlayerCache(playerDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("error while initializing player cache: %v", err)
+	}
 	lobbyPlayerId, err := util.GetEnvUUID("LOBBY_USER")
 	if err != nil {
 		return nil, fmt.Errorf("error while loading lobby user env: %v", err)
 	}
-	core := &CoreFacade{db: db, lobbyAdapter: lobbyAdapter, lobbyPlayerId: lobbyPlayerId}
-	core.startCleanUp()
+	maxWait, err := util.GetEnvIntWithFallback("LONG_POLL_MAX_WAIT", 30)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading max wait for long polling from environment variable: %v", err)
+	}
+	refreshInterval, err := util.GetEnvIntWithFallback("PLAYER_REFRESH_INTERVAL", 5)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading player refresh interval from environment variable: %v", err)
+	}
+	if refreshInterval <= 0 {
+		return nil, fmt.Errorf("player refresh interval has to be positive but was %d", refreshInterval)
+	}
+	scavengerConfig, err := loadScavengerConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error while loading scavenger config: %v", err)
+	}
+	topics, err := loadTopicRegistry()
+	if err != nil {
+		return nil, fmt.Errorf("error while loading topic registry: %v", err)
+	}
+	reservedTopics := make(map[string]bool)
+	for _, topic := range util.GetEnvListWithFallback("RESERVED_TOPICS", []string{player_joins_lobby_topic}) {
+		reservedTopics[topic] = true
+	}
+	spectatorTeam := util.GetEnvWithFallback("SPECTATOR_TEAM", "spectators")
+	leader, err := newLeaderElection(db)
+	if err != nil {
+		return nil, fmt.Errorf("error while initializing leader election: %v", err)
+	}
+	refresher := newPlayerRefresher(playerCache, time.Duration(refreshInterval)*time.Second)
+	notifier := newLobbyNotifier()
+	db.ListenMessages(notifier.notify, playerCache.InvalidatePlayer)
+	core := &CoreFacade{db: db, playerDirectory: playerCache, playerCache: playerCache, refresher: refresher, leader: leader, archive: adapter.NewMessageArchive(), topics: topics, reservedTopics: reservedTopics, spectatorTeam: spectatorTeam, lobbyPlayerId: lobbyPlayerId, notifier: notifier, maxWait: time.Duration(maxWait) * time.Second, closing: make(chan struct{}), closeOnce: &sync.Once{}}
+	refresher.start()
+	leader.start()
+	core.scheduler = core.startCleanUp(scavengerConfig)
 	return core, nil
 }
+
+// Shutdown lets waiting requests return immediately with the messages available, so they do not delay the shutdown.
+func (core CoreFacade) Shutdown() {
+	core.closeOnce.Do(func() { close(core.closing) })
+}
+
+// Close stops all background jobs, sends the pending refreshes of players and closes the database. Call it after all requests are done.
+func (core CoreFacade) Close() {
+	core.Shutdown()
+	core.scheduler.Stop()
+	core.leader.stop()
+	core.refresher.stop()
+	core.db.Close()
+}
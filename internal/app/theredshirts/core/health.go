@@ -0,0 +1,39 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+)
+
+const (
+	health_check_database  = "database"
+	health_check_migration = "migration"
+	health_check_lobby     = "lobby"
+)
+
+// CheckHealth runs the checks of all dependencies at once and returns the result per dependency, nil if the check passed.
+func (core CoreFacade) CheckHealth(context *util.Context) map[string]error {
+	checks := map[string]func() error{
+		health_check_database:  core.db.Ping,
+		health_check_migration: core.db.CheckMigration,
+		health_check_lobby:     func() error { return core.playerDirectory.Ping(context) },
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]error, len(checks))
+	for name, check := range checks {
+		name, check := name, check
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := check()
+			mutex.Lock()
+			defer mutex.Unlock()
+			results[name] = err
+		}()
+	}
+	wg.Wait()
+	return results
+}
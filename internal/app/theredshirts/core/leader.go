@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/db"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// leaderElection decides which instance of the service runs the tasks that should only run once, like the scavenger.
+	leaderElection struct {
+		lock       db.LeaderLock
+		instanceId string
+		interval   time.Duration
+		mutex      sync.RWMutex
+		leader     bool
+		done       chan struct{}
+		stopped    chan struct{}
+	}
+)
+
+func newLeaderElection(database db.DB) (*leaderElection, error) {
+	instanceId := util.GetEnvWithFallback("INSTANCE_ID", "")
+	if instanceId == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = uuid.NewString()
+		}
+		instanceId = hostname
+	}
+	interval, err := util.GetEnvIntWithFallback("LEADER_ELECTION_INTERVAL", 10)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading leader election interval from environment variable: %v", err)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("leader election interval has to be positive but was %d", interval)
+	}
+	return &leaderElection{lock: database.NewLeaderLock(instanceId), instanceId: instanceId, interval: time.Duration(interval) * time.Second, done: make(chan struct{}), stopped: make(chan struct{})}, nil
+}
+
+func (election *leaderElection) start() {
+	log.Infof("Start leader election as instance %s", election.instanceId)
+	election.elect()
+	go func() {
+		defer close(election.stopped)
+		ticker := time.NewTicker(election.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				election.elect()
+			case <-election.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop gives up the leadership, so another instance can take over without waiting for the connection to time out.
+func (election *leaderElection) stop() {
+	close(election.done)
+	<-election.stopped
+	election.mutex.Lock()
+	defer election.mutex.Unlock()
+	election.leader = false
+	if err := election.lock.Release(); err != nil {
+		log.Warnf("Error while releasing leadership: %v", err)
+	}
+}
+
+func (election *leaderElection) elect() {
+	leader, err := election.lock.TryAcquire()
+	if err != nil {
+		log.Warnf("Error while electing leader: %v", err)
+	}
+
+	election.mutex.Lock()
+	defer election.mutex.Unlock()
+	if leader != election.leader {
+		log.Infof("Instance %s changed leadership, leader: %t", election.instanceId, leader)
+	}
+	election.leader = leader
+}
+
+func (election *leaderElection) isLeader() bool {
+	election.mutex.RLock()
+	defer election.mutex.RUnlock()
+	return election.leader
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLeaderLock struct {
+	mutex    sync.Mutex
+	acquire  bool
+	err      error
+	released bool
+}
+
+func (lock *testLeaderLock) TryAcquire() (bool, error) {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	return lock.acquire, lock.err
+}
+
+func (lock *testLeaderLock) Release() error {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	lock.released = true
+	return nil
+}
+
+func (lock *testLeaderLock) Leader() (string, error) {
+	return "some-instance", nil
+}
+
+func newTestLeaderElection(lock *testLeaderLock) *leaderElection {
+	return &leaderElection{lock: lock, instanceId: "some-instance", interval: time.Hour, done: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+func TestLeaderElection_Acquire(t *testing.T) {
+	election := newTestLeaderElection(&testLeaderLock{acquire: true})
+
+	election.elect()
+	assert.True(t, election.isLeader())
+}
+
+func TestLeaderElection_Lose(t *testing.T) {
+	lock := &testLeaderLock{acquire: true}
+	election := newTestLeaderElection(lock)
+	election.elect()
+
+	lock.acquire = false
+	lock.err = errors.New("connection lost")
+	election.elect()
+	assert.False(t, election.isLeader())
+}
+
+func TestLeaderElection_ReleaseOnStop(t *testing.T) {
+	lock := &testLeaderLock{acquire: true}
+	election := newTestLeaderElection(lock)
+	election.start()
+	assert.True(t, election.isLeader())
+
+	election.stop()
+	assert.False(t, election.isLeader())
+	assert.True(t, lock.released)
+}
+
+func TestGetLeader_NotLobbyUser(t *testing.T) {
+	core := newTestCore(t)
+	core.leader = newTestLeaderElection(&testLeaderLock{acquire: true})
+
+	_, err := core.GetLeader(newTestContext(), uuid.New())
+	assert.ErrorIs(t, err, ErrNotLobbyUser)
+	leader, err := core.GetLeader(newTestContext(), core.lobbyPlayerId)
+	assert.Nil(t, err)
+	assert.Equal(t, "some-instance", leader.LeaderInstanceId)
+}
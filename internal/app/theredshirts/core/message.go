@@ -3,14 +3,22 @@ package core
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
 	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/db"
 	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
 	"github.com/google/uuid"
 )
 
-func (core CoreFacade) CreateMessage(context *util.Context, message *Message) error {
+// CreateMessage stores the message of the requester. Only the lobby user can write messages in the name of another player,
+// for all others the player of the message has to be empty or the requester.
+func (core CoreFacade) CreateMessage(context *util.Context, requesterId uuid.UUID, message *Message) error {
 	context.Logger.Debugf("Create Message: %+v", *message)
+	// the lobby service is asked before the transaction, which locks the numbering of the lobby
+	if err := core.prepareMessage(context, requesterId, message); err != nil {
+		return err
+	}
 	tx, err := core.db.StartTransaction()
 	if err != nil {
 		return err
@@ -19,70 +27,274 @@ func (core CoreFacade) CreateMessage(context *util.Context, message *Message) er
 	if err != nil {
 		return fmt.Errorf("something went wrong while creating transaction: %v", err)
 	}
-	if err := core.createMessage(context, tx, message); err != nil {
+	dbMessage := mapToDBMessage(message)
+	if err := tx.CreateMessage(dbMessage); err != nil {
+		if errors.Is(err, db.ErrMessageAlreadyExists) {
+			context.Logger.Debugf("Message %v already exists", message.ID)
+			return nil
+		}
+		return fmt.Errorf("error while creating message: %v", err)
+	}
+	message.Number = dbMessage.Number
+	if err := tx.Commit(); err != nil {
 		return err
 	}
-	return tx.Commit()
+	core.notifier.notify(message.LobbyId)
+	return nil
 }
 
-func (core CoreFacade) createMessage(context *util.Context, tx db.DBTx, message *Message) error {
-	if message.PlayerId != core.lobbyPlayerId {
-		player, err := core.lobbyAdapter.GetPlayer(context, message.PlayerId)
+// prepareMessage checks if the requester may write the message and resolves its audience.
+func (core CoreFacade) prepareMessage(context *util.Context, requesterId uuid.UUID, message *Message) error {
+	if message.PlayerId == uuid.Nil {
+		message.PlayerId = requesterId
+	}
+	var player *adapter.SimplePlayer
+	if requesterId != core.lobbyPlayerId {
+		if message.PlayerId != requesterId {
+			return fmt.Errorf("%w: player %v is not allowed to write in the name of player %v", ErrNotLobbyUser, requesterId, message.PlayerId)
+		}
+		// the latest PLAYER_JOINS_LOBBY message of a player decides which messages the player gets first, players must not fake it
+		if core.reservedTopics[message.Topic] {
+			return fmt.Errorf("%w: player %v is not allowed to write topic %s", ErrReservedTopic, message.PlayerId, message.Topic)
+		}
+		var err error
+		player, err = core.playerDirectory.GetPlayer(context, message.PlayerId)
 		if err != nil {
-			return fmt.Errorf("error while getting player %v: %v", message.PlayerId, err)
+			return fmt.Errorf("error while getting player %v: %w", message.PlayerId, err)
 		}
 
 		if player.LobbyId != message.LobbyId {
-			return fmt.Errorf("error player %v from lobby %v is not authorised to write in lobby %v", message.PlayerId, player.LobbyId, message.LobbyId)
+			return fmt.Errorf("%w: player %v from lobby %v is not authorised to write in lobby %v", ErrNotLobbyMember, message.PlayerId, player.LobbyId, message.LobbyId)
 		}
 	}
+	if err := core.resolveChannel(context, message, player); err != nil {
+		return err
+	}
+	if err := core.checkRecipients(context, message); err != nil {
+		return err
+	}
 
-	if err := tx.CreateMessage(mapToDBMessage(message)); err != nil {
-		if !errors.Is(err, db.ErrMessageAlreadyExists) {
-			return fmt.Errorf("error while creating message: %v", err)
+	if core.topics != nil {
+		if err := core.topics.validate(message.Topic, message.Message); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (core CoreFacade) GetMessages(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID, number int) ([]*Message, error) {
+// checkRecipients fails with ErrValidation if a recipient of the message is not a player of the lobby.
+func (core CoreFacade) checkRecipients(context *util.Context, message *Message) error {
+	for _, recipientId := range message.Recipients {
+		if recipientId == uuid.Nil {
+			return fmt.Errorf("%w: recipient must not be empty", ErrValidation)
+		}
+		recipient, err := core.playerDirectory.GetPlayer(context, recipientId)
+		if errors.Is(err, ErrPlayerNotFound) {
+			return fmt.Errorf("%w: recipient %v is unknown", ErrValidation, recipientId)
+		}
+		if err != nil {
+			return fmt.Errorf("error while getting recipient %v: %w", recipientId, err)
+		}
+		if recipient.LobbyId != message.LobbyId {
+			return fmt.Errorf("%w: recipient %v is not in lobby %v", ErrValidation, recipientId, message.LobbyId)
+		}
+	}
+	return nil
+}
+
+// EditMessage replaces the content of a message of the requester, only the lobby user can edit the messages of other players.
+// The previous content is kept as revision and the message gets the next number of the lobby, so players polling with their
+// last number get the edited message. Players who joined after the message was sent do not get the edit. The old number of the message is left as gap.
+func (core CoreFacade) EditMessage(context *util.Context, requesterId uuid.UUID, message *Message) error {
+	context.Logger.Debugf("Edit Message: %+v", *message)
+	// the lobby service is asked before the message is locked
+	if requesterId != core.lobbyPlayerId {
+		if _, err := core.checkPlayerInLobby(context, requesterId, message.LobbyId); err != nil {
+			return err
+		}
+	}
+	tx, err := core.db.StartTransaction()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	dbMessage, err := tx.GetMessage(message.LobbyId, message.ID)
+	if err != nil {
+		return fmt.Errorf("error while loading message %v: %w", message.ID, err)
+	}
+	if requesterId != core.lobbyPlayerId {
+		if dbMessage.PlayerId != requesterId {
+			return fmt.Errorf("%w: player %v is not allowed to edit the message of player %v", ErrNotLobbyUser, requesterId, dbMessage.PlayerId)
+		}
+		// editing moves the message to the next number, that must not happen to a PLAYER_JOINS_LOBBY message
+		if core.reservedTopics[dbMessage.Topic] {
+			return fmt.Errorf("%w: player %v is not allowed to edit topic %s", ErrReservedTopic, requesterId, dbMessage.Topic)
+		}
+	}
+	if core.topics != nil {
+		if err := core.topics.validate(dbMessage.Topic, message.Message); err != nil {
+			return err
+		}
+	}
+
+	dbMessage.Message = message.Message
+	if err := tx.EditMessage(dbMessage); err != nil {
+		return fmt.Errorf("error while editing message: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	core.notifier.notify(message.LobbyId)
+	*message = *mapToMessage(dbMessage)
+	return nil
+}
+
+// GetMessageTopic returns the topic of a message of the lobby, the requester has to be member of the lobby.
+func (core CoreFacade) GetMessageTopic(context *util.Context, requesterId uuid.UUID, lobbyId uuid.UUID, messageId uuid.UUID) (string, error) {
+	if err := core.CheckLobbyMember(context, requesterId, lobbyId); err != nil {
+		return "", err
+	}
+	tx, err := core.db.StartTransaction()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	message, err := tx.GetMessage(lobbyId, messageId)
+	if err != nil {
+		return "", fmt.Errorf("error while loading message %v: %w", messageId, err)
+	}
+	return message.Topic, tx.Commit()
+}
+
+func (core CoreFacade) GetMessages(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID, number int, wait time.Duration) ([]*Message, error) {
+	player, err := core.checkPlayerInLobby(context, playerId, lobbyId)
+	if err != nil {
+		return nil, err
+	}
+	if wait <= 0 {
+		return core.readMessages(context, playerId, player.Team, lobbyId, number)
+	}
+	if wait > core.maxWait {
+		wait = core.maxWait
+	}
+
+	listener := core.notifier.subscribe(lobbyId)
+	defer core.notifier.unsubscribe(lobbyId, listener)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		messages, err := core.readMessages(context, playerId, player.Team, lobbyId, number)
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+		select {
+		case <-listener:
+		case <-timer.C:
+			return messages, nil
+		case <-core.closing:
+			return messages, nil
+		case <-context.Done():
+			return messages, nil
+		}
+	}
+}
+
+func (core CoreFacade) readMessages(context *util.Context, playerId uuid.UUID, team string, lobbyId uuid.UUID, number int) ([]*Message, error) {
 	tx, err := core.db.StartTransaction()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	messages, err := core.getMessages(context, tx, playerId, lobbyId, number)
+	messages, err := core.loadMessages(context, tx, playerId, team, lobbyId, number)
 	if err != nil {
 		return nil, err
 	}
 	return messages, tx.Commit()
 }
 
-func (core CoreFacade) getMessages(context *util.Context, tx db.DBTx, playerId uuid.UUID, lobbyId uuid.UUID, number int) ([]*Message, error) {
-	player, err := core.lobbyAdapter.GetPlayer(context, playerId)
+// CheckLobbyMember fails with ErrNotLobbyMember if the player is not in the lobby, the lobby user is member of every lobby.
+func (core CoreFacade) CheckLobbyMember(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID) error {
+	if playerId == core.lobbyPlayerId {
+		return nil
+	}
+	_, err := core.checkPlayerInLobby(context, playerId, lobbyId)
+	return err
+}
+
+func (core CoreFacade) checkPlayerInLobby(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID) (*adapter.SimplePlayer, error) {
+	player, err := core.playerDirectory.GetPlayer(context, playerId)
 	if err != nil {
-		return nil, fmt.Errorf("error while getting player %v: %v", playerId, err)
+		return nil, fmt.Errorf("error while getting player %v: %w", playerId, err)
 	}
 
 	if player.LobbyId != lobbyId {
-		return nil, fmt.Errorf("error player %v from lobby %v is not authorised to load messages from lobby %v", playerId, player.LobbyId, lobbyId)
+		return nil, fmt.Errorf("%w: player %v from lobby %v is not authorised to load messages from lobby %v", ErrNotLobbyMember, playerId, player.LobbyId, lobbyId)
 	}
+	return player, nil
+}
+
+func (core CoreFacade) loadMessages(context *util.Context, tx db.DBTx, playerId uuid.UUID, team string, lobbyId uuid.UUID, number int) ([]*Message, error) {
 	var messages []*db.Message
+	var err error
 	if number != -1 {
-		messages, err = tx.GetMessages(lobbyId, playerId, number)
+		messages, err = tx.GetMessages(lobbyId, playerId, team, number)
 	} else {
-		messages, err = tx.GetMessagesFirstRequest(lobbyId, playerId)
+		messages, err = tx.GetMessagesFirstRequest(lobbyId, playerId, team)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("something went wrong while loading messages in lobby [%v] from database: %v", lobbyId, err)
 	}
 
-	if err := core.lobbyAdapter.UpdatePlayerLastRefresh(context, playerId); err != nil {
-		return nil, fmt.Errorf("error while updating player %v: %v", playerId, err)
+	core.refresher.refresh(playerId)
+	return mapToMessages(messages), nil
+}
+
+// DeleteLobbyMessages removes all messages and the numbering of a closed lobby at once. If an archive is configured, the messages are archived
+// and deleted in batches instead, a failed purge leaves part of the messages and is completed by calling it again.
+func (core CoreFacade) DeleteLobbyMessages(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID) error {
+	context.Logger.Debugf("Delete messages of lobby %v", lobbyId)
+	if playerId != core.lobbyPlayerId {
+		return ErrNotLobbyUser
 	}
 
-	return mapToMessages(messages), nil
+	deleted, err := core.deleteMessages(&messageDeletion{
+		load: func(tx db.DBTx, limit int) ([]*db.Message, error) {
+			return tx.GetLobbyMessages(lobbyId, limit)
+		},
+		delete: func(tx db.DBTx) (int64, error) {
+			deleted, err := tx.DeleteLobbyMessages(lobbyId)
+			if err != nil {
+				return 0, err
+			}
+			return deleted, tx.DeleteLobbySequence(lobbyId)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error while deleting messages of lobby %v: %v", lobbyId, err)
+	}
+	// archived messages are deleted by id, the sequence is left to remove
+	if core.archive != nil {
+		if err := core.deleteLobbySequence(lobbyId); err != nil {
+			return fmt.Errorf("error while deleting sequence of lobby %v: %v", lobbyId, err)
+		}
+	}
+	context.Logger.Debugf("Deleted %d messages of lobby %v", deleted, lobbyId)
+	return nil
+}
+
+func (core CoreFacade) deleteLobbySequence(lobbyId uuid.UUID) error {
+	tx, err := core.db.StartTransaction()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := tx.DeleteLobbySequence(lobbyId); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func mapToMessages(dbMessages []*db.Message) []*Message {
@@ -94,9 +306,9 @@ func mapToMessages(dbMessages []*db.Message) []*Message {
 }
 
 func mapToMessage(message *db.Message) *Message {
-	return &Message{ID: message.ID, SendTime: message.SendTime, LobbyId: message.LobbyId, PlayerId: message.PlayerId, Number: message.Number, Topic: message.Topic, Message: message.Message}
+	return &Message{ID: message.ID, SendTime: message.SendTime, LobbyId: message.LobbyId, PlayerId: message.PlayerId, Number: message.Number, Topic: message.Topic, Message: message.Message, Direct: message.Direct, Channel: message.Channel, Revision: message.Revision}
 }
 
 func mapToDBMessage(message *Message) *db.Message {
-	return &db.Message{ID: message.ID, SendTime: message.SendTime, LobbyId: message.LobbyId, PlayerId: message.PlayerId, Number: message.Number, Topic: message.Topic, Message: message.Message}
+	return &db.Message{ID: message.ID, SendTime: message.SendTime, LobbyId: message.LobbyId, PlayerId: message.PlayerId, Number: message.Number, Topic: message.Topic, Message: message.Message, Recipients: message.Recipients, Channel: message.Channel, Team: message.Team}
 }
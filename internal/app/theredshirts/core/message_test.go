@@ -0,0 +1,300 @@
+package core
+
+import (
+	stdcontext "context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/db"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCore struct {
+	*CoreFacade
+	directory *adapter.StaticPlayerDirectory
+}
+
+func newTestCore(t *testing.T) *testCore {
+	t.Setenv("DATABASE", "inmemory")
+	database, err := db.NewConnection()
+	assert.Nil(t, err)
+	directory := adapter.NewStaticPlayerDirectory()
+	core := &CoreFacade{
+		db:              database,
+		playerDirectory: directory,
+		refresher:       newPlayerRefresher(directory, time.Hour),
+		lobbyPlayerId:   uuid.New(),
+		notifier:        newLobbyNotifier(),
+		reservedTopics:  map[string]bool{player_joins_lobby_topic: true},
+		spectatorTeam:   "spectators",
+		closing:         make(chan struct{}),
+		closeOnce:       &sync.Once{},
+	}
+	return &testCore{CoreFacade: core, directory: directory}
+}
+
+func newTestContext() *util.Context {
+	return &util.Context{CorrelationId: "test", Logger: log.WithFields(log.Fields{})}
+}
+
+func (core *testCore) newPlayer(lobbyId uuid.UUID) uuid.UUID {
+	return core.newTeamPlayer(lobbyId, "")
+}
+
+func (core *testCore) newTeamPlayer(lobbyId uuid.UUID, team string) uuid.UUID {
+	player := &adapter.SimplePlayer{ID: uuid.New(), LobbyId: lobbyId, Team: team}
+	core.directory.PutPlayer(player)
+	return player.ID
+}
+
+func newTestMessage(lobbyId uuid.UUID, playerId uuid.UUID, topic string) *Message {
+	return &Message{ID: uuid.New(), SendTime: time.Now(), LobbyId: lobbyId, PlayerId: playerId, Topic: topic, Message: map[string]interface{}{}}
+}
+
+func TestCreateMessage_ReservedTopicByPlayer(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+
+	err := core.CreateMessage(newTestContext(), somePlayerId, newTestMessage(someLobbyId, somePlayerId, player_joins_lobby_topic))
+	assert.ErrorIs(t, err, ErrReservedTopic)
+}
+
+func TestCreateMessage_ReservedTopicByLobbyUser(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+
+	message := newTestMessage(someLobbyId, somePlayerId, player_joins_lobby_topic)
+	err := core.CreateMessage(newTestContext(), core.lobbyPlayerId, message)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, message.Number)
+	assert.Equal(t, somePlayerId, message.PlayerId)
+}
+
+func TestCreateMessage_InNameOfOtherPlayer(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	otherPlayerId := core.newPlayer(someLobbyId)
+
+	err := core.CreateMessage(newTestContext(), somePlayerId, newTestMessage(someLobbyId, otherPlayerId, "CHAT"))
+	assert.ErrorIs(t, err, ErrNotLobbyUser)
+}
+
+func TestCreateMessage_OtherTopicByPlayer(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+
+	message := newTestMessage(someLobbyId, uuid.Nil, "CHAT")
+	err := core.CreateMessage(newTestContext(), somePlayerId, message)
+	assert.Nil(t, err)
+	assert.Equal(t, somePlayerId, message.PlayerId)
+}
+
+func TestGetMessages_FirstRequestNotResetByOtherPlayer(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	otherPlayerId := core.newPlayer(someLobbyId)
+	assert.Nil(t, core.CreateMessage(newTestContext(), core.lobbyPlayerId, newTestMessage(someLobbyId, somePlayerId, player_joins_lobby_topic)))
+	assert.Nil(t, core.CreateMessage(newTestContext(), otherPlayerId, newTestMessage(someLobbyId, otherPlayerId, "CHAT")))
+
+	spoofedJoinMessage := newTestMessage(someLobbyId, somePlayerId, player_joins_lobby_topic)
+	assert.ErrorIs(t, core.CreateMessage(newTestContext(), otherPlayerId, spoofedJoinMessage), ErrNotLobbyUser)
+	spoofedJoinMessage = newTestMessage(someLobbyId, otherPlayerId, player_joins_lobby_topic)
+	assert.ErrorIs(t, core.CreateMessage(newTestContext(), otherPlayerId, spoofedJoinMessage), ErrReservedTopic)
+
+	messages, err := core.GetMessages(newTestContext(), somePlayerId, someLobbyId, -1, 0)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "CHAT", messages[0].Topic)
+}
+
+func TestGetMessages_TeamChannel(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newTeamPlayer(someLobbyId, "red")
+	teamPlayerId := core.newTeamPlayer(someLobbyId, "red")
+	otherTeamPlayerId := core.newTeamPlayer(someLobbyId, "blue")
+	spectatorId := core.newTeamPlayer(someLobbyId, "spectators")
+
+	teamMessage := newTestMessage(someLobbyId, somePlayerId, "CHAT")
+	teamMessage.Channel = channel_team
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, teamMessage))
+	spectatorMessage := newTestMessage(someLobbyId, spectatorId, "CHAT")
+	spectatorMessage.Channel = channel_spectators
+	assert.Nil(t, core.CreateMessage(newTestContext(), spectatorId, spectatorMessage))
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, newTestMessage(someLobbyId, somePlayerId, "CHAT")))
+
+	messages, err := core.GetMessages(newTestContext(), teamPlayerId, someLobbyId, 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, teamMessage.ID, messages[0].ID)
+	assert.Equal(t, channel_team, messages[0].Channel)
+	assert.Equal(t, channel_lobby, messages[1].Channel)
+
+	messages, err = core.GetMessages(newTestContext(), otherTeamPlayerId, someLobbyId, 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, channel_lobby, messages[0].Channel)
+
+	messages, err = core.GetMessages(newTestContext(), somePlayerId, someLobbyId, 0, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestCreateMessage_TeamChannelWithoutTeam(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+
+	message := newTestMessage(someLobbyId, somePlayerId, "CHAT")
+	message.Channel = channel_team
+	err := core.CreateMessage(newTestContext(), somePlayerId, message)
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestEditMessage_OwnMessage(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	otherPlayerId := core.newPlayer(someLobbyId)
+	message := newTestMessage(someLobbyId, somePlayerId, "CHAT")
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, message))
+	assert.Nil(t, core.CreateMessage(newTestContext(), otherPlayerId, newTestMessage(someLobbyId, otherPlayerId, "CHAT")))
+
+	edit := &Message{ID: message.ID, LobbyId: someLobbyId, Message: map[string]interface{}{"text": "edited"}}
+	assert.ErrorIs(t, core.EditMessage(newTestContext(), otherPlayerId, edit), ErrNotLobbyUser)
+	assert.Nil(t, core.EditMessage(newTestContext(), somePlayerId, edit))
+	assert.Equal(t, 3, edit.Number)
+	assert.Equal(t, 2, edit.Revision)
+	assert.Equal(t, somePlayerId, edit.PlayerId)
+
+	messages, err := core.GetMessages(newTestContext(), otherPlayerId, someLobbyId, 1, 0)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "edited", messages[0].Message["text"])
+}
+
+func TestEditMessage_ReservedTopicByPlayer(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	message := newTestMessage(someLobbyId, somePlayerId, player_joins_lobby_topic)
+	assert.Nil(t, core.CreateMessage(newTestContext(), core.lobbyPlayerId, message))
+
+	err := core.EditMessage(newTestContext(), somePlayerId, &Message{ID: message.ID, LobbyId: someLobbyId})
+	assert.ErrorIs(t, err, ErrReservedTopic)
+}
+
+func TestEditMessage_NotFound(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+
+	err := core.EditMessage(newTestContext(), somePlayerId, &Message{ID: uuid.New(), LobbyId: someLobbyId})
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+}
+
+func TestEditMessage_NotLobbyMember(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	message := newTestMessage(someLobbyId, somePlayerId, "CHAT")
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, message))
+	core.directory.PutPlayer(&adapter.SimplePlayer{ID: somePlayerId, LobbyId: uuid.New()})
+
+	err := core.EditMessage(newTestContext(), somePlayerId, &Message{ID: message.ID, LobbyId: someLobbyId})
+	assert.ErrorIs(t, err, ErrNotLobbyMember)
+}
+
+func TestGetMessages_ClientGone(t *testing.T) {
+	core := newTestCore(t)
+	core.maxWait = time.Minute
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	request, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+
+	context := newTestContext()
+	context.Request = request
+	messages, err := core.GetMessages(context, somePlayerId, someLobbyId, 0, time.Minute)
+	assert.Nil(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestCheckLobbyMember_Member(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+
+	assert.Nil(t, core.CheckLobbyMember(newTestContext(), core.newPlayer(someLobbyId), someLobbyId))
+	assert.Nil(t, core.CheckLobbyMember(newTestContext(), core.lobbyPlayerId, someLobbyId))
+}
+
+func TestCheckLobbyMember_OtherLobby(t *testing.T) {
+	core := newTestCore(t)
+	somePlayerId := core.newPlayer(uuid.New())
+
+	assert.ErrorIs(t, core.CheckLobbyMember(newTestContext(), somePlayerId, uuid.New()), ErrNotLobbyMember)
+}
+
+func TestCreateMessage_Recipients(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	recipientId := core.newPlayer(someLobbyId)
+	otherPlayerId := core.newPlayer(someLobbyId)
+
+	message := newTestMessage(someLobbyId, somePlayerId, "CHAT")
+	message.Recipients = []uuid.UUID{recipientId}
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, message))
+
+	messages, err := core.GetMessages(newTestContext(), recipientId, someLobbyId, 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.True(t, messages[0].Direct)
+	messages, err = core.GetMessages(newTestContext(), otherPlayerId, someLobbyId, 0, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestCreateMessage_RecipientNotInLobby(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+
+	for name, recipientId := range map[string]uuid.UUID{"empty": uuid.Nil, "unknown": uuid.New(), "other lobby": core.newPlayer(uuid.New())} {
+		t.Run(name, func(t *testing.T) {
+			message := newTestMessage(someLobbyId, somePlayerId, "CHAT")
+			message.Recipients = []uuid.UUID{recipientId}
+			assert.ErrorIs(t, core.CreateMessage(newTestContext(), somePlayerId, message), ErrValidation)
+		})
+	}
+
+	messages, err := core.GetMessages(newTestContext(), core.newPlayer(someLobbyId), someLobbyId, 0, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestGetMessageTopic_Member(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	message := newTestMessage(someLobbyId, somePlayerId, "CHAT")
+	assert.Nil(t, core.CreateMessage(newTestContext(), somePlayerId, message))
+
+	topic, err := core.GetMessageTopic(newTestContext(), core.newPlayer(someLobbyId), someLobbyId, message.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "CHAT", topic)
+	_, err = core.GetMessageTopic(newTestContext(), core.newPlayer(uuid.New()), someLobbyId, message.ID)
+	assert.ErrorIs(t, err, ErrNotLobbyMember)
+	_, err = core.GetMessageTopic(newTestContext(), somePlayerId, someLobbyId, uuid.New())
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+}
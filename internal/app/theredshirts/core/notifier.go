@@ -0,0 +1,59 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type (
+	lobbyNotifier struct {
+		mutex     sync.Mutex
+		listeners map[uuid.UUID]map[chan struct{}]struct{}
+	}
+)
+
+func newLobbyNotifier() *lobbyNotifier {
+	return &lobbyNotifier{listeners: make(map[uuid.UUID]map[chan struct{}]struct{})}
+}
+
+func (notifier *lobbyNotifier) subscribe(lobbyId uuid.UUID) chan struct{} {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	listener := make(chan struct{}, 1)
+	lobbyListeners, ok := notifier.listeners[lobbyId]
+	if !ok {
+		lobbyListeners = make(map[chan struct{}]struct{})
+		notifier.listeners[lobbyId] = lobbyListeners
+	}
+	lobbyListeners[listener] = struct{}{}
+	return listener
+}
+
+func (notifier *lobbyNotifier) unsubscribe(lobbyId uuid.UUID, listener chan struct{}) {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	lobbyListeners, ok := notifier.listeners[lobbyId]
+	if !ok {
+		return
+	}
+	delete(lobbyListeners, listener)
+	if len(lobbyListeners) == 0 {
+		delete(notifier.listeners, lobbyId)
+	}
+}
+
+// notify wakes up every listener of the lobby. Listeners that were not woken up yet keep their pending signal, so several notifications collapse into one.
+func (notifier *lobbyNotifier) notify(lobbyId uuid.UUID) {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	for listener := range notifier.listeners[lobbyId] {
+		select {
+		case listener <- struct{}{}:
+		default:
+		}
+	}
+}
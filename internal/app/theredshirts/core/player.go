@@ -0,0 +1,21 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+)
+
+func (core CoreFacade) InvalidatePlayer(context *util.Context, requesterId uuid.UUID, playerId uuid.UUID) error {
+	context.Logger.Debugf("Invalidate player %v", playerId)
+	if requesterId != core.lobbyPlayerId {
+		return ErrNotLobbyUser
+	}
+	core.playerCache.InvalidatePlayer(playerId)
+	// the other instances of the service cache the player as well
+	if err := core.db.NotifyPlayerInvalidated(playerId); err != nil {
+		return fmt.Errorf("error while notifying other instances about player %v: %v", playerId, err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidatePlayer_LoadedAgain(t *testing.T) {
+	core := newTestCore(t)
+	playerCache, err := adapter.NewPlayerCache(core.directory)
+	assert.Nil(t, err)
+	core.playerCache = playerCache
+	core.playerDirectory = playerCache
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	assert.Nil(t, core.CheckLobbyMember(newTestContext(), somePlayerId, someLobbyId))
+	core.directory.PutPlayer(&adapter.SimplePlayer{ID: somePlayerId, LobbyId: uuid.New()})
+	assert.Nil(t, core.CheckLobbyMember(newTestContext(), somePlayerId, someLobbyId))
+
+	assert.ErrorIs(t, core.InvalidatePlayer(newTestContext(), somePlayerId, somePlayerId), ErrNotLobbyUser)
+	assert.Nil(t, core.InvalidatePlayer(newTestContext(), core.lobbyPlayerId, somePlayerId))
+	assert.ErrorIs(t, core.CheckLobbyMember(newTestContext(), somePlayerId, someLobbyId), ErrNotLobbyMember)
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+const refresh_workers = 8
+
+var (
+	playerRefreshCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "message",
+		Name:      "player_refresh_total",
+		Help:      "Number of last refresh updates sent to the lobby service by result.",
+	}, []string{"result"})
+)
+
+type (
+	// playerRefresher collects the players which loaded messages and tells the lobby service about them in the background.
+	// A player loading messages several times within an interval is only sent once.
+	playerRefresher struct {
+		directory adapter.PlayerDirectory
+		interval  time.Duration
+		mutex     sync.Mutex
+		pending   map[uuid.UUID]struct{}
+		done      chan struct{}
+		stopped   chan struct{}
+	}
+)
+
+func newPlayerRefresher(directory adapter.PlayerDirectory, interval time.Duration) *playerRefresher {
+	return &playerRefresher{directory: directory, interval: interval, pending: make(map[uuid.UUID]struct{}), done: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+func (refresher *playerRefresher) start() {
+	log.Infof("Start refreshing players every %v", refresher.interval)
+	go func() {
+		defer close(refresher.stopped)
+		ticker := time.NewTicker(refresher.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				refresher.flush()
+			case <-refresher.done:
+				refresher.flush()
+				return
+			}
+		}
+	}()
+}
+
+// stop sends the pending refreshes and waits until they are done.
+func (refresher *playerRefresher) stop() {
+	close(refresher.done)
+	<-refresher.stopped
+}
+
+func (refresher *playerRefresher) refresh(playerId uuid.UUID) {
+	refresher.mutex.Lock()
+	defer refresher.mutex.Unlock()
+	refresher.pending[playerId] = struct{}{}
+}
+
+func (refresher *playerRefresher) flush() {
+	refresher.mutex.Lock()
+	pending := refresher.pending
+	refresher.pending = make(map[uuid.UUID]struct{})
+	refresher.mutex.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	correlationId := uuid.NewString()
+	context := &util.Context{CorrelationId: correlationId, Logger: log.WithFields(log.Fields{"Refresher": correlationId})}
+	context.Logger.Debugf("Refresh %d players", len(pending))
+
+	playerIds := make(chan uuid.UUID)
+	var wg sync.WaitGroup
+	for worker := 0; worker < refresh_workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for playerId := range playerIds {
+				if err := refresher.directory.UpdatePlayerLastRefresh(context, playerId); err != nil {
+					context.Logger.Warnf("Error while refreshing player %v: %v", playerId, err)
+					playerRefreshCounter.WithLabelValues("failure").Inc()
+					continue
+				}
+				playerRefreshCounter.WithLabelValues("success").Inc()
+			}
+		}()
+	}
+	for playerId := range pending {
+		playerIds <- playerId
+	}
+	close(playerIds)
+	wg.Wait()
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// countingDirectory counts the refreshes per player and fails them for the players in failing.
+	countingDirectory struct {
+		*adapter.StaticPlayerDirectory
+		mutex     sync.Mutex
+		refreshes map[uuid.UUID]int
+		failing   map[uuid.UUID]bool
+	}
+)
+
+func newCountingDirectory() *countingDirectory {
+	return &countingDirectory{StaticPlayerDirectory: adapter.NewStaticPlayerDirectory(), refreshes: make(map[uuid.UUID]int), failing: make(map[uuid.UUID]bool)}
+}
+
+func (directory *countingDirectory) UpdatePlayerLastRefresh(context *util.Context, playerId uuid.UUID) error {
+	directory.mutex.Lock()
+	defer directory.mutex.Unlock()
+	directory.refreshes[playerId]++
+	if directory.failing[playerId] {
+		return errors.New("lobby service not available")
+	}
+	return nil
+}
+
+func (directory *countingDirectory) getRefreshes(playerId uuid.UUID) int {
+	directory.mutex.Lock()
+	defer directory.mutex.Unlock()
+	return directory.refreshes[playerId]
+}
+
+func TestPlayerRefresher_RefreshedOncePerInterval(t *testing.T) {
+	directory := newCountingDirectory()
+	refresher := newPlayerRefresher(directory, time.Hour)
+	somePlayerId := uuid.New()
+	otherPlayerId := uuid.New()
+
+	refresher.refresh(somePlayerId)
+	refresher.refresh(somePlayerId)
+	refresher.refresh(otherPlayerId)
+	refresher.refresh(somePlayerId)
+	refresher.flush()
+
+	assert.Equal(t, 1, directory.getRefreshes(somePlayerId))
+	assert.Equal(t, 1, directory.getRefreshes(otherPlayerId))
+
+	refresher.flush()
+	assert.Equal(t, 1, directory.getRefreshes(somePlayerId))
+
+	refresher.refresh(somePlayerId)
+	refresher.flush()
+	assert.Equal(t, 2, directory.getRefreshes(somePlayerId))
+}
+
+func TestPlayerRefresher_FailingRefreshNotBlocking(t *testing.T) {
+	directory := newCountingDirectory()
+	refresher := newPlayerRefresher(directory, 10*time.Millisecond)
+	failingPlayerId := uuid.New()
+	somePlayerId := uuid.New()
+	directory.failing[failingPlayerId] = true
+	refresher.start()
+
+	refresher.refresh(failingPlayerId)
+	refresher.refresh(somePlayerId)
+	assert.Eventually(t, func() bool { return directory.getRefreshes(somePlayerId) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	refresher.refresh(failingPlayerId)
+	refresher.refresh(somePlayerId)
+	assert.Eventually(t, func() bool { return directory.getRefreshes(somePlayerId) == 2 }, 5*time.Second, 10*time.Millisecond)
+	refresher.stop()
+
+	assert.Equal(t, 2, directory.getRefreshes(failingPlayerId))
+}
+
+func TestPlayerRefresher_PendingSentOnStop(t *testing.T) {
+	directory := newCountingDirectory()
+	refresher := newPlayerRefresher(directory, time.Hour)
+	somePlayerId := uuid.New()
+	refresher.start()
+
+	refresher.refresh(somePlayerId)
+	refresher.stop()
+
+	assert.Equal(t, 1, directory.getRefreshes(somePlayerId))
+}
@@ -1,36 +1,129 @@
 package core
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/db"
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
 	"github.com/go-co-op/gocron"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
 )
 
-func (core CoreFacade) startCleanUp() {
-	log.Info("Start auto cleanup of messages")
+var (
+	scavengerDeletedHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "message",
+		Name:      "scavenger_deleted_messages",
+		Help:      "Number of messages deleted per run of the scavenger.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	})
+	scavengerFailedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "message",
+		Name:      "scavenger_failed_runs_total",
+		Help:      "Number of runs of the scavenger which failed.",
+	})
+)
+
+type (
+	scavengerConfig struct {
+		interval  time.Duration
+		retention time.Duration
+		// topicRetention overrides the retention for single topics, a negative retention keeps the messages as long as the lobby exists
+		topicRetention map[string]time.Duration
+	}
+)
+
+func loadScavengerConfig() (*scavengerConfig, error) {
+	interval, err := util.GetEnvIntWithFallback("MESSAGE_CLEANUP_INTERVAL", 60)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading cleanup interval from environment variable: %v", err)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("cleanup interval has to be positive but was %d", interval)
+	}
+	retention, err := util.GetEnvIntWithFallback("MESSAGE_RETENTION", 30)
+	if err != nil {
+		return nil, fmt.Errorf("error while loading message retention from environment variable: %v", err)
+	}
+	// a retention of 0 or less would delete every message with the next run
+	if retention <= 0 {
+		return nil, fmt.Errorf("message retention has to be positive but was %d", retention)
+	}
+	topicRetention, err := util.GetEnvIntMapWithFallback("MESSAGE_RETENTION_TOPICS", map[string]int{})
+	if err != nil {
+		return nil, fmt.Errorf("error while loading message retention of topics from environment variable: %v", err)
+	}
+
+	// the PLAYER_JOINS_LOBBY messages decide which messages a player gets, they are kept unless configured otherwise
+	config := &scavengerConfig{interval: time.Duration(interval) * time.Second, retention: time.Duration(retention) * time.Second, topicRetention: map[string]time.Duration{player_joins_lobby_topic: -time.Second}}
+	for topic, topicRetention := range topicRetention {
+		config.topicRetention[topic] = time.Duration(topicRetention) * time.Second
+	}
+	return config, nil
+}
+
+func (core CoreFacade) startCleanUp(config *scavengerConfig) *gocron.Scheduler {
+	log.Infof("Start auto cleanup of messages every %v", config.interval)
 	s := gocron.NewScheduler(time.UTC)
 
-	s.Every(60).Seconds().Do(func() {
+	s.Every(config.interval).Do(func() {
 		correlationId := uuid.NewString()
 		logger := log.WithFields(log.Fields{
 			"Scavenger": correlationId,
 		})
 
-		tx, err := core.db.StartTransaction()
-		if err != nil {
-			logger.Warnf("something went wrong while creating transaction: %v", err)
+		if !core.leader.isLeader() {
+			logger.Debug("Skip cleanup, instance is not the leader")
 			return
 		}
-		defer tx.Rollback()
 
-		if err := tx.DeleteMessages(time.Now().Add(-30 * time.Second)); err != nil {
-			log.Warn("Error while deleting old messages: %v", err)
+		deleted, err := core.cleanUp(config)
+		if err != nil {
+			scavengerFailedCounter.Inc()
+			logger.Warnf("Error while deleting old messages: %v", err)
 			return
 		}
-		tx.Commit()
+		scavengerDeletedHistogram.Observe(float64(deleted))
+		logger.Debugf("Deleted %d old messages", deleted)
 	})
 
-	//s.StartAsync()
+	s.StartAsync()
+	return s
+}
+
+func (core CoreFacade) cleanUp(config *scavengerConfig) (int64, error) {
+	now := time.Now()
+	keepTopics := make([]string, 0, len(config.topicRetention))
+	for topic := range config.topicRetention {
+		keepTopics = append(keepTopics, topic)
+	}
+	before := now.Add(-config.retention)
+	deletions := []*messageDeletion{{
+		load: func(tx db.DBTx, limit int) ([]*db.Message, error) {
+			return tx.GetMessagesOlderThan(before, keepTopics, limit)
+		},
+		delete: func(tx db.DBTx) (int64, error) {
+			return tx.DeleteMessages(before, keepTopics)
+		},
+	}}
+
+	for topic, retention := range config.topicRetention {
+		if retention < 0 {
+			continue
+		}
+		topic := topic
+		topicBefore := now.Add(-retention)
+		deletions = append(deletions, &messageDeletion{
+			load: func(tx db.DBTx, limit int) ([]*db.Message, error) {
+				return tx.GetTopicMessagesOlderThan(topic, topicBefore, limit)
+			},
+			delete: func(tx db.DBTx) (int64, error) {
+				return tx.DeleteTopicMessages(topic, topicBefore)
+			},
+		})
+	}
+	return core.deleteMessages(deletions...)
 }
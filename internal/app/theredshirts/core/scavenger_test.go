@@ -0,0 +1,34 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadScavengerConfig_Retention(t *testing.T) {
+	t.Setenv("MESSAGE_RETENTION", "120")
+
+	config, err := loadScavengerConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Minute, config.retention)
+}
+
+func TestLoadScavengerConfig_RetentionNotPositive(t *testing.T) {
+	for _, retention := range []string{"0", "-30"} {
+		t.Setenv("MESSAGE_RETENTION", retention)
+
+		_, err := loadScavengerConfig()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestLoadScavengerConfig_TopicRetentionKeepsJoins(t *testing.T) {
+	t.Setenv("MESSAGE_RETENTION_TOPICS", "GAME_STATE=60")
+
+	config, err := loadScavengerConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, config.topicRetention["GAME_STATE"])
+	assert.Equal(t, -time.Second, config.topicRetention[player_joins_lobby_topic])
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/google/uuid"
+)
+
+type (
+	Subscription interface {
+		Notifications() <-chan struct{}
+		GetMessages(context *util.Context, number int) ([]*Message, error)
+		// Refresh checks again if the player is in the lobby and takes over a changed team of the player
+		Refresh(context *util.Context) error
+		Close()
+	}
+
+	lobbySubscription struct {
+		core     CoreFacade
+		playerId uuid.UUID
+		team     string
+		lobbyId  uuid.UUID
+		listener chan struct{}
+	}
+)
+
+func (core CoreFacade) SubscribeMessages(context *util.Context, playerId uuid.UUID, lobbyId uuid.UUID) (Subscription, error) {
+	player, err := core.checkPlayerInLobby(context, playerId, lobbyId)
+	if err != nil {
+		return nil, err
+	}
+	listener := core.notifier.subscribe(lobbyId)
+	return &lobbySubscription{core: core, playerId: playerId, team: player.Team, lobbyId: lobbyId, listener: listener}, nil
+}
+
+func (subscription *lobbySubscription) Notifications() <-chan struct{} {
+	return subscription.listener
+}
+
+// GetMessages loads the messages after number without asking the lobby again, the player was already authorised when subscribing.
+// The team of the player is the one of the time of subscribing or the last refresh.
+func (subscription *lobbySubscription) GetMessages(context *util.Context, number int) ([]*Message, error) {
+	return subscription.core.readMessages(context, subscription.playerId, subscription.team, subscription.lobbyId, number)
+}
+
+func (subscription *lobbySubscription) Refresh(context *util.Context) error {
+	player, err := subscription.core.checkPlayerInLobby(context, subscription.playerId, subscription.lobbyId)
+	if err != nil {
+		return err
+	}
+	subscription.team = player.Team
+	return nil
+}
+
+func (subscription *lobbySubscription) Close() {
+	subscription.core.notifier.unsubscribe(subscription.lobbyId, subscription.listener)
+}
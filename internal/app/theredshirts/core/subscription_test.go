@@ -0,0 +1,36 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/adapter"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionRefresh_TeamChanged(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newTeamPlayer(someLobbyId, "red")
+	subscription, err := core.SubscribeMessages(newTestContext(), somePlayerId, someLobbyId)
+	assert.Nil(t, err)
+	defer subscription.Close()
+
+	core.directory.PutPlayer(&adapter.SimplePlayer{ID: somePlayerId, LobbyId: someLobbyId, Team: "blue"})
+
+	assert.Nil(t, subscription.Refresh(newTestContext()))
+	assert.Equal(t, "blue", subscription.(*lobbySubscription).team)
+}
+
+func TestSubscriptionRefresh_PlayerLeftLobby(t *testing.T) {
+	core := newTestCore(t)
+	someLobbyId := uuid.New()
+	somePlayerId := core.newPlayer(someLobbyId)
+	subscription, err := core.SubscribeMessages(newTestContext(), somePlayerId, someLobbyId)
+	assert.Nil(t, err)
+	defer subscription.Close()
+
+	core.directory.RemovePlayer(somePlayerId)
+
+	assert.NotNil(t, subscription.Refresh(newTestContext()))
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const topic_schema_url = "mem://topics/%s.json"
+
+type (
+	// topicRegistry knows the allowed topics and validates the messages of a topic against its JSON Schema.
+	// Unknown topics are rejected unless the registry is lenient.
+	topicRegistry struct {
+		lenient bool
+		topics  map[string]*registeredTopic
+	}
+
+	registeredTopic struct {
+		// schema is nil if the messages of the topic are not validated
+		schema *jsonschema.Schema
+	}
+
+	topicRegistryFile struct {
+		Lenient bool                            `json:"lenient"`
+		Topics  map[string]*topicRegistryConfig `json:"topics"`
+	}
+
+	topicRegistryConfig struct {
+		Schema json.RawMessage `json:"schema"`
+	}
+)
+
+// loadTopicRegistry returns nil if no registry is configured, every topic is allowed then.
+func loadTopicRegistry() (*topicRegistry, error) {
+	path := util.GetEnvWithFallback("TOPIC_REGISTRY_FILE", "")
+	if path == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading topic registry from %s: %v", path, err)
+	}
+	return parseTopicRegistry(content)
+}
+
+func parseTopicRegistry(content []byte) (*topicRegistry, error) {
+	var file topicRegistryFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("error while parsing topic registry: %v", err)
+	}
+
+	registry := &topicRegistry{lenient: file.Lenient, topics: make(map[string]*registeredTopic)}
+	compiler := jsonschema.NewCompiler()
+	for topic, config := range file.Topics {
+		registered := &registeredTopic{}
+		if config != nil && len(config.Schema) > 0 {
+			schemaUrl := fmt.Sprintf(topic_schema_url, url.PathEscape(topic))
+			if err := compiler.AddResource(schemaUrl, bytes.NewReader(config.Schema)); err != nil {
+				return nil, fmt.Errorf("error while loading schema of topic %s: %v", topic, err)
+			}
+			schema, err := compiler.Compile(schemaUrl)
+			if err != nil {
+				return nil, fmt.Errorf("error while compiling schema of topic %s: %v", topic, err)
+			}
+			registered.schema = schema
+		}
+		registry.topics[topic] = registered
+	}
+	return registry, nil
+}
+
+// validate returns ErrValidation if the topic is unknown or the message does not match the schema of the topic.
+func (registry *topicRegistry) validate(topic string, message map[string]interface{}) error {
+	registered, ok := registry.topics[topic]
+	if !ok {
+		if registry.lenient {
+			return nil
+		}
+		return fmt.Errorf("%w: topic %s is unknown", ErrValidation, topic)
+	}
+	if registered.schema == nil {
+		return nil
+	}
+
+	var document interface{} = map[string]interface{}{}
+	if message != nil {
+		document = message
+	}
+	if err := registered.schema.Validate(document); err != nil {
+		return fmt.Errorf("%w: message does not match schema of topic %s: %v", ErrValidation, topic, err)
+	}
+	return nil
+}
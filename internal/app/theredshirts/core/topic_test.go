@@ -0,0 +1,50 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const test_topic_registry = `{
+	"topics": {
+		"CHAT": {"schema": {"type": "object", "required": ["text"], "properties": {"text": {"type": "string", "maxLength": 5}}, "additionalProperties": false}},
+		"MOVE": {"schema": {"type": "object", "properties": {"x": {"type": "integer"}}}},
+		"PING": {}
+	}
+}`
+
+func TestTopicRegistryValidate_Valid(t *testing.T) {
+	registry, err := parseTopicRegistry([]byte(test_topic_registry))
+	assert.Nil(t, err)
+
+	assert.Nil(t, registry.validate("CHAT", map[string]interface{}{"text": "hello"}))
+	assert.Nil(t, registry.validate("MOVE", map[string]interface{}{"x": float64(3)}))
+	assert.Nil(t, registry.validate("PING", map[string]interface{}{"anything": true}))
+	assert.Nil(t, registry.validate("MOVE", nil))
+}
+
+func TestTopicRegistryValidate_Invalid(t *testing.T) {
+	registry, err := parseTopicRegistry([]byte(test_topic_registry))
+	assert.Nil(t, err)
+
+	assert.ErrorIs(t, registry.validate("CHAT", map[string]interface{}{"text": "too long"}), ErrValidation)
+	assert.ErrorIs(t, registry.validate("CHAT", map[string]interface{}{"text": "hi", "other": 1}), ErrValidation)
+	assert.ErrorIs(t, registry.validate("CHAT", nil), ErrValidation)
+	assert.ErrorIs(t, registry.validate("MOVE", map[string]interface{}{"x": 1.5}), ErrValidation)
+}
+
+func TestTopicRegistryValidate_UnknownTopic(t *testing.T) {
+	registry, err := parseTopicRegistry([]byte(test_topic_registry))
+	assert.Nil(t, err)
+	lenientRegistry, err := parseTopicRegistry([]byte(`{"lenient": true, "topics": {"CHAT": {}}}`))
+	assert.Nil(t, err)
+
+	assert.ErrorIs(t, registry.validate("UNKNOWN", nil), ErrValidation)
+	assert.Nil(t, lenientRegistry.validate("UNKNOWN", nil))
+}
+
+func TestParseTopicRegistry_InvalidSchema(t *testing.T) {
+	_, err := parseTopicRegistry([]byte(`{"topics": {"CHAT": {"schema": {"type": "no type"}}}}`))
+	assert.NotNil(t, err)
+}
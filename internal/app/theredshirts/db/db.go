@@ -1,7 +1,7 @@
 package db
 
 import (
-	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -18,11 +18,41 @@ type (
 		Number   int                    `db:"number"`
 		Topic    string                 `db:"topic"`
 		Message  map[string]interface{} `db:"message"`
+		// Direct is set by CreateMessage if the message has recipients, direct messages are only returned to the recipients
+		Direct bool `db:"direct"`
+		// Recipients are stored by CreateMessage but not loaded with the messages
+		Recipients []uuid.UUID `db:"-"`
+		// Channel is the audience of the message the player has chosen, the team is resolved from it
+		Channel string `db:"channel"`
+		// Team is empty for messages to the whole lobby, otherwise only players of the team get the message
+		Team string `db:"team"`
+		// Revision counts the contents of the message, it is 1 until the message is edited
+		Revision int `db:"revision"`
+	}
+
+	// MessageRevision is a replaced content of an edited message
+	MessageRevision struct {
+		MessageId uuid.UUID              `db:"message_id"`
+		Revision  int                    `db:"revision"`
+		Number    int                    `db:"number"`
+		Message   map[string]interface{} `db:"message"`
 	}
 
 	DB interface {
 		Close()
 		StartTransaction() (DBTx, error)
+		ListenMessages(notify func(lobbyId uuid.UUID), invalidatePlayer func(playerId uuid.UUID))
+		NotifyPlayerInvalidated(playerId uuid.UUID) error
+		NewLeaderLock(instanceId string) LeaderLock
+		Ping() error
+		CheckMigration() error
+	}
+
+	// LeaderLock is held by at most one instance of the service at a time.
+	LeaderLock interface {
+		TryAcquire() (bool, error)
+		Release() error
+		Leader() (string, error)
 	}
 
 	DBTx interface {
@@ -30,21 +60,37 @@ type (
 		Rollback() error
 		//Message
 		CreateMessage(message *Message) error
-		GetMessages(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, number int) ([]*Message, error)
-		GetMessagesFirstRequest(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID) ([]*Message, error)
-		DeleteMessages(time time.Time) error
+		GetMessage(lobbyId uuid.UUID, messageId uuid.UUID) (*Message, error)
+		EditMessage(message *Message) error
+		GetMessages(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, team string, number int) ([]*Message, error)
+		GetMessagesFirstRequest(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, team string) ([]*Message, error)
+		GetMessagesOlderThan(time time.Time, keepTopics []string, limit int) ([]*Message, error)
+		GetTopicMessagesOlderThan(topic string, time time.Time, limit int) ([]*Message, error)
+		GetLobbyMessages(lobbyId uuid.UUID, limit int) ([]*Message, error)
+		DeleteMessages(time time.Time, keepTopics []string) (int64, error)
+		DeleteTopicMessages(topic string, time time.Time) (int64, error)
+		DeleteLobbyMessages(lobbyId uuid.UUID) (int64, error)
+		DeleteMessagesById(messageIds []uuid.UUID) (int64, error)
+		GetMessageRevisions(messageIds []uuid.UUID) ([]*MessageRevision, error)
+		DeleteLobbySequence(lobbyId uuid.UUID) error
 	}
 )
 
 const (
 	schema_name = "theredshirts_message"
+	// ping_timeout limits how long a health check waits for the database
+	ping_timeout = 5 * time.Second
 )
 
 func NewConnection() (DB, error) {
 	switch db := strings.ToLower(util.GetEnvWithFallback("DATABASE", "postgresql")); db {
 	case "postgresql":
 		return newPostgresConnection()
+	case "sqlite":
+		return newSqliteConnection()
+	case "inmemory":
+		return newInMemoryConnection(), nil
 	default:
-		return nil, errors.New("no configuration for %s found")
+		return nil, fmt.Errorf("no configuration for %s found", db)
 	}
 }
@@ -0,0 +1,438 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	player_joins_lobby_topic = "PLAYER_JOINS_LOBBY"
+)
+
+var (
+	errTransactionDone = errors.New("transaction is already committed or rolled back")
+)
+
+type (
+	inMemoryConnection struct {
+		mutex     sync.RWMutex
+		lobbies   map[uuid.UUID]*inMemoryLobby
+		messages  map[uuid.UUID]*Message
+		revisions map[uuid.UUID][]*Message
+	}
+
+	inMemoryLobby struct {
+		number   int
+		messages []*Message
+	}
+
+	// inMemoryTransaction reads without holding the connection. With the first write the connection is locked until the transaction ends,
+	// every write registers how to undo it in case of a rollback.
+	inMemoryTransaction struct {
+		connection *inMemoryConnection
+		locked     bool
+		done       bool
+		undo       []func()
+	}
+)
+
+func newInMemoryConnection() DB {
+	return &inMemoryConnection{lobbies: make(map[uuid.UUID]*inMemoryLobby), messages: make(map[uuid.UUID]*Message), revisions: make(map[uuid.UUID][]*Message)}
+}
+
+func (connection *inMemoryConnection) Close() {
+}
+
+func (connection *inMemoryConnection) Ping() error {
+	return nil
+}
+
+// CheckMigration always succeeds, there is no schema to migrate.
+func (connection *inMemoryConnection) CheckMigration() error {
+	return nil
+}
+
+func (connection *inMemoryConnection) StartTransaction() (DBTx, error) {
+	return &inMemoryTransaction{connection: connection}, nil
+}
+
+// ListenMessages does nothing, there are no other instances sharing the messages.
+func (connection *inMemoryConnection) ListenMessages(notify func(lobbyId uuid.UUID), invalidatePlayer func(playerId uuid.UUID)) {
+}
+
+// NotifyPlayerInvalidated does nothing, there are no other instances caching players.
+func (connection *inMemoryConnection) NotifyPlayerInvalidated(playerId uuid.UUID) error {
+	return nil
+}
+
+func (connection *inMemoryConnection) NewLeaderLock(instanceId string) LeaderLock {
+	return newLocalLeaderLock(instanceId)
+}
+
+func (tx *inMemoryTransaction) Commit() error {
+	if tx.done {
+		return errTransactionDone
+	}
+	tx.finish()
+	return nil
+}
+
+func (tx *inMemoryTransaction) Rollback() error {
+	if tx.done {
+		return errTransactionDone
+	}
+	for index := len(tx.undo) - 1; index >= 0; index-- {
+		tx.undo[index]()
+	}
+	tx.finish()
+	return nil
+}
+
+func (tx *inMemoryTransaction) finish() {
+	tx.done = true
+	tx.undo = nil
+	if tx.locked {
+		tx.locked = false
+		tx.connection.mutex.Unlock()
+	}
+}
+
+func (tx *inMemoryTransaction) lock() {
+	if !tx.locked {
+		tx.connection.mutex.Lock()
+		tx.locked = true
+	}
+}
+
+func (tx *inMemoryTransaction) read(read func()) {
+	if tx.locked {
+		read()
+		return
+	}
+	tx.connection.mutex.RLock()
+	defer tx.connection.mutex.RUnlock()
+	read()
+}
+
+func (tx *inMemoryTransaction) CreateMessage(message *Message) error {
+	if tx.done {
+		return errTransactionDone
+	}
+	tx.lock()
+	connection := tx.connection
+	if _, ok := connection.messages[message.ID]; ok {
+		return ErrMessageAlreadyExists
+	}
+
+	lobby, ok := connection.lobbies[message.LobbyId]
+	if !ok {
+		lobby = &inMemoryLobby{}
+		connection.lobbies[message.LobbyId] = lobby
+		tx.undo = append(tx.undo, func() { delete(connection.lobbies, message.LobbyId) })
+	}
+
+	message.Direct = len(message.Recipients) > 0
+	storedMessage := *message
+	storedMessage.Recipients = append([]uuid.UUID(nil), message.Recipients...)
+	storedMessage.Number = lobby.number + 1
+	storedMessage.Revision = 1
+	previousMessages := lobby.messages
+	lobby.number = storedMessage.Number
+	lobby.messages = append(lobby.messages[:len(lobby.messages):len(lobby.messages)], &storedMessage)
+	connection.messages[storedMessage.ID] = &storedMessage
+	tx.undo = append(tx.undo, func() {
+		lobby.number--
+		lobby.messages = previousMessages
+		delete(connection.messages, storedMessage.ID)
+	})
+
+	message.Number = storedMessage.Number
+	return nil
+}
+
+// GetMessage locks the connection until the transaction ends.
+func (tx *inMemoryTransaction) GetMessage(lobbyId uuid.UUID, messageId uuid.UUID) (*Message, error) {
+	if tx.done {
+		return nil, errTransactionDone
+	}
+	tx.lock()
+	message, ok := tx.connection.messages[messageId]
+	if !ok || message.LobbyId != lobbyId {
+		return nil, ErrMessageNotFound
+	}
+	copiedMessage := *message
+	copiedMessage.Recipients = nil
+	return &copiedMessage, nil
+}
+
+// EditMessage moves the message to the end of its lobby with the next number, the replaced message is kept as revision.
+func (tx *inMemoryTransaction) EditMessage(message *Message) error {
+	if tx.done {
+		return errTransactionDone
+	}
+	tx.lock()
+	connection := tx.connection
+	storedMessage, ok := connection.messages[message.ID]
+	if !ok {
+		return ErrMessageNotFound
+	}
+	lobby := connection.lobbies[storedMessage.LobbyId]
+
+	editedMessage := *storedMessage
+	editedMessage.Message = message.Message
+	editedMessage.Number = lobby.number + 1
+	editedMessage.Revision = storedMessage.Revision + 1
+	previousMessages := lobby.messages
+	previousRevisions := connection.revisions[message.ID]
+	var messages []*Message
+	for _, lobbyMessage := range lobby.messages {
+		if lobbyMessage.ID != message.ID {
+			messages = append(messages, lobbyMessage)
+		}
+	}
+	lobby.number = editedMessage.Number
+	lobby.messages = append(messages, &editedMessage)
+	connection.messages[message.ID] = &editedMessage
+	connection.revisions[message.ID] = append(previousRevisions[:len(previousRevisions):len(previousRevisions)], storedMessage)
+	tx.undo = append(tx.undo, func() {
+		lobby.number--
+		lobby.messages = previousMessages
+		connection.messages[message.ID] = storedMessage
+		connection.revisions[message.ID] = previousRevisions
+	})
+
+	message.Number = editedMessage.Number
+	message.Revision = editedMessage.Revision
+	return nil
+}
+
+func (tx *inMemoryTransaction) GetMessages(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, team string, number int) ([]*Message, error) {
+	if tx.done {
+		return nil, errTransactionDone
+	}
+	var messages []*Message
+	tx.read(func() {
+		messages = tx.selectMessages(lobbyId, toIgnoreplayerId, team, number)
+	})
+	return messages, nil
+}
+
+func (tx *inMemoryTransaction) GetMessagesFirstRequest(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, team string) ([]*Message, error) {
+	if tx.done {
+		return nil, errTransactionDone
+	}
+	var messages []*Message
+	tx.read(func() {
+		if joinNumber, ok := tx.joinNumber(lobbyId, toIgnoreplayerId); ok {
+			messages = tx.selectMessages(lobbyId, toIgnoreplayerId, team, joinNumber)
+		}
+	})
+	return messages, nil
+}
+
+// joinNumber returns the number of the latest PLAYER_JOINS_LOBBY message of the player.
+func (tx *inMemoryTransaction) joinNumber(lobbyId uuid.UUID, playerId uuid.UUID) (int, bool) {
+	lobby, ok := tx.connection.lobbies[lobbyId]
+	if !ok {
+		return 0, false
+	}
+	for index := len(lobby.messages) - 1; index >= 0; index-- {
+		message := lobby.messages[index]
+		if message.PlayerId == playerId && message.Topic == player_joins_lobby_topic {
+			return message.Number, true
+		}
+	}
+	return 0, false
+}
+
+func (tx *inMemoryTransaction) selectMessages(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, team string, number int) []*Message {
+	lobby, ok := tx.connection.lobbies[lobbyId]
+	if !ok {
+		return nil
+	}
+	// edited messages are only returned to players who joined before the first revision of the message
+	joinNumber, _ := tx.joinNumber(lobbyId, toIgnoreplayerId)
+	var messages []*Message
+	for _, message := range lobby.messages {
+		if message.Number > number && message.PlayerId != toIgnoreplayerId && (message.Team == "" || message.Team == team) && (!message.Direct || isRecipient(message, toIgnoreplayerId)) &&
+			(message.Revision == 1 || tx.connection.revisions[message.ID][0].Number > joinNumber) {
+			copiedMessage := *message
+			copiedMessage.Recipients = nil
+			messages = append(messages, &copiedMessage)
+		}
+	}
+	return messages
+}
+
+func isRecipient(message *Message, playerId uuid.UUID) bool {
+	for _, recipient := range message.Recipients {
+		if recipient == playerId {
+			return true
+		}
+	}
+	return false
+}
+
+func (tx *inMemoryTransaction) GetMessagesOlderThan(time time.Time, keepTopics []string, limit int) ([]*Message, error) {
+	return tx.findMessages(olderThan(time, keepTopics), sendTimeOrder, limit)
+}
+
+func (tx *inMemoryTransaction) GetTopicMessagesOlderThan(topic string, time time.Time, limit int) ([]*Message, error) {
+	return tx.findMessages(topicOlderThan(topic, time), sendTimeOrder, limit)
+}
+
+func (tx *inMemoryTransaction) GetLobbyMessages(lobbyId uuid.UUID, limit int) ([]*Message, error) {
+	return tx.findMessages(ofLobby(lobbyId), numberOrder, limit)
+}
+
+func (tx *inMemoryTransaction) DeleteMessages(time time.Time, keepTopics []string) (int64, error) {
+	return tx.deleteMessages(olderThan(time, keepTopics))
+}
+
+func (tx *inMemoryTransaction) DeleteTopicMessages(topic string, time time.Time) (int64, error) {
+	return tx.deleteMessages(topicOlderThan(topic, time))
+}
+
+func (tx *inMemoryTransaction) DeleteLobbyMessages(lobbyId uuid.UUID) (int64, error) {
+	return tx.deleteMessages(ofLobby(lobbyId))
+}
+
+func (tx *inMemoryTransaction) DeleteLobbySequence(lobbyId uuid.UUID) error {
+	if tx.done {
+		return errTransactionDone
+	}
+	tx.lock()
+	connection := tx.connection
+	lobby, ok := connection.lobbies[lobbyId]
+	if !ok || len(lobby.messages) > 0 {
+		return nil
+	}
+	delete(connection.lobbies, lobbyId)
+	tx.undo = append(tx.undo, func() { connection.lobbies[lobbyId] = lobby })
+	return nil
+}
+
+func (tx *inMemoryTransaction) DeleteMessagesById(messageIds []uuid.UUID) (int64, error) {
+	ids := make(map[uuid.UUID]bool, len(messageIds))
+	for _, messageId := range messageIds {
+		ids[messageId] = true
+	}
+	return tx.deleteMessages(func(message *Message) bool {
+		return ids[message.ID]
+	})
+}
+
+func (tx *inMemoryTransaction) GetMessageRevisions(messageIds []uuid.UUID) ([]*MessageRevision, error) {
+	if tx.done {
+		return nil, errTransactionDone
+	}
+	var revisions []*MessageRevision
+	tx.read(func() {
+		for _, messageId := range messageIds {
+			for _, revision := range tx.connection.revisions[messageId] {
+				revisions = append(revisions, &MessageRevision{MessageId: messageId, Revision: revision.Revision, Number: revision.Number, Message: revision.Message})
+			}
+		}
+	})
+	return revisions, nil
+}
+
+func olderThan(time time.Time, keepTopics []string) func(message *Message) bool {
+	keep := make(map[string]bool, len(keepTopics))
+	for _, topic := range keepTopics {
+		keep[topic] = true
+	}
+	return func(message *Message) bool {
+		return message.SendTime.Before(time) && !keep[message.Topic]
+	}
+}
+
+func topicOlderThan(topic string, time time.Time) func(message *Message) bool {
+	return func(message *Message) bool {
+		return message.Topic == topic && message.SendTime.Before(time)
+	}
+}
+
+func ofLobby(lobbyId uuid.UUID) func(message *Message) bool {
+	return func(message *Message) bool {
+		return message.LobbyId == lobbyId
+	}
+}
+
+func sendTimeOrder(first *Message, second *Message) bool {
+	return first.SendTime.Before(second.SendTime)
+}
+
+func numberOrder(first *Message, second *Message) bool {
+	return first.Number < second.Number
+}
+
+func (tx *inMemoryTransaction) findMessages(matches func(message *Message) bool, less func(first *Message, second *Message) bool, limit int) ([]*Message, error) {
+	if tx.done {
+		return nil, errTransactionDone
+	}
+	var messages []*Message
+	tx.read(func() {
+		for _, message := range tx.connection.messages {
+			if matches(message) {
+				copiedMessage := *message
+				copiedMessage.Recipients = nil
+				messages = append(messages, &copiedMessage)
+			}
+		}
+	})
+	sort.Slice(messages, func(first int, second int) bool {
+		return less(messages[first], messages[second])
+	})
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (tx *inMemoryTransaction) deleteMessages(toDelete func(message *Message) bool) (int64, error) {
+	if tx.done {
+		return 0, errTransactionDone
+	}
+	tx.lock()
+	connection := tx.connection
+	var deleted int64
+	for _, lobby := range connection.lobbies {
+		lobby := lobby
+		previousMessages := lobby.messages
+		var keptMessages []*Message
+		var deletedMessages []*Message
+		deletedRevisions := make(map[uuid.UUID][]*Message)
+		for _, message := range lobby.messages {
+			if toDelete(message) {
+				deleted++
+				deletedMessages = append(deletedMessages, message)
+				delete(connection.messages, message.ID)
+				if revisions, ok := connection.revisions[message.ID]; ok {
+					deletedRevisions[message.ID] = revisions
+					delete(connection.revisions, message.ID)
+				}
+			} else {
+				keptMessages = append(keptMessages, message)
+			}
+		}
+		if len(deletedMessages) == 0 {
+			continue
+		}
+		lobby.messages = keptMessages
+		tx.undo = append(tx.undo, func() {
+			lobby.messages = previousMessages
+			for _, message := range deletedMessages {
+				connection.messages[message.ID] = message
+			}
+			for messageId, revisions := range deletedRevisions {
+				connection.revisions[messageId] = revisions
+			}
+		})
+	}
+	return deleted, nil
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// leader_lock_key identifies the advisory lock, it is small enough to be found in pg_locks as objid without classid
+	leader_lock_key          = 4711
+	try_advisory_lock_sql    = "SELECT pg_try_advisory_lock($1)"
+	advisory_unlock_sql      = "SELECT pg_advisory_unlock($1)"
+	select_leader_lock_owner = "SELECT a.application_name FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid WHERE l.locktype = 'advisory' AND l.granted AND l.classid::bigint = 0 AND l.objid::bigint = $1 AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())"
+)
+
+type (
+	// postgresLeaderLock holds a session level advisory lock on its own connection. If the instance dies the connection is closed and
+	// postgres releases the lock, so another instance can acquire it.
+	postgresLeaderLock struct {
+		connection *postgresConnection
+		instanceId string
+		conn       *pgx.Conn
+		held       bool
+	}
+
+	localLeaderLock struct {
+		instanceId string
+	}
+)
+
+func (connection *postgresConnection) NewLeaderLock(instanceId string) LeaderLock {
+	return &postgresLeaderLock{connection: connection, instanceId: instanceId}
+}
+
+func (lock *postgresLeaderLock) TryAcquire() (bool, error) {
+	if lock.conn != nil {
+		pingContext, cancel := context.WithTimeout(context.Background(), ping_timeout)
+		err := lock.conn.Ping(pingContext)
+		cancel()
+		if err != nil {
+			lock.closeConnection()
+			return false, fmt.Errorf("connection holding the leader lock lost: %v", err)
+		}
+		if lock.held {
+			return true, nil
+		}
+	} else {
+		config, err := pgx.ParseConfig(lock.connection.url)
+		if err != nil {
+			return false, fmt.Errorf("error while parsing database url: %v", err)
+		}
+		config.RuntimeParams["application_name"] = lock.instanceId
+		conn, err := pgx.ConnectConfig(context.Background(), config)
+		if err != nil {
+			return false, fmt.Errorf("unable to connect to database: %v", err)
+		}
+		lock.conn = conn
+	}
+
+	if err := lock.conn.QueryRow(context.Background(), try_advisory_lock_sql, leader_lock_key).Scan(&lock.held); err != nil {
+		lock.closeConnection()
+		return false, fmt.Errorf("error while trying to acquire leader lock: %v", err)
+	}
+	return lock.held, nil
+}
+
+func (lock *postgresLeaderLock) Release() error {
+	if lock.conn == nil {
+		return nil
+	}
+	defer lock.closeConnection()
+	if !lock.held {
+		return nil
+	}
+	if _, err := lock.conn.Exec(context.Background(), advisory_unlock_sql, leader_lock_key); err != nil {
+		return fmt.Errorf("error while releasing leader lock: %v", err)
+	}
+	return nil
+}
+
+func (lock *postgresLeaderLock) Leader() (string, error) {
+	var leader string
+	if err := lock.connection.dbPool.QueryRow(context.Background(), select_leader_lock_owner, leader_lock_key).Scan(&leader); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error while selecting leader: %v", err)
+	}
+	return leader, nil
+}
+
+func (lock *postgresLeaderLock) closeConnection() {
+	lock.conn.Close(context.Background())
+	lock.conn = nil
+	lock.held = false
+}
+
+// newLocalLeaderLock is used by databases which are not shared between instances, the only instance is always the leader.
+func newLocalLeaderLock(instanceId string) LeaderLock {
+	return &localLeaderLock{instanceId: instanceId}
+}
+
+func (lock *localLeaderLock) TryAcquire() (bool, error) {
+	return true, nil
+}
+
+func (lock *localLeaderLock) Release() error {
+	return nil
+}
+
+func (lock *localLeaderLock) Leader() (string, error) {
+	return lock.instanceId, nil
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalLeaderLock_AlwaysLeader(t *testing.T) {
+	lock := newLocalLeaderLock("some-instance")
+
+	leader, err := lock.TryAcquire()
+	assert.Nil(t, err)
+	assert.True(t, leader)
+	instanceId, err := lock.Leader()
+	assert.Nil(t, err)
+	assert.Equal(t, "some-instance", instanceId)
+
+	assert.Nil(t, lock.Release())
+	leader, err = lock.TryAcquire()
+	assert.Nil(t, err)
+	assert.True(t, leader)
+}
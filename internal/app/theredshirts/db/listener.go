@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	message_channel         = "theredshirts_message"
+	player_channel          = "theredshirts_message_player"
+	listen_message_sql      = "LISTEN " + message_channel
+	listen_player_sql       = "LISTEN " + player_channel
+	notify_message_sql      = "SELECT pg_notify($1, $2)"
+	listener_retry_interval = 5 * time.Second
+)
+
+// ListenMessages calls notify for every message created in a lobby and invalidatePlayer for every player invalidated in the cache,
+// including messages and players of other instances of the service.
+func (connection *postgresConnection) ListenMessages(notify func(lobbyId uuid.UUID), invalidatePlayer func(playerId uuid.UUID)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	connection.stopListener = cancel
+	go func() {
+		for {
+			err := connection.listen(ctx, notify, invalidatePlayer)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warnf("Listener for messages stopped, retrying in %v: %v", listener_retry_interval, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(listener_retry_interval):
+			}
+		}
+	}()
+}
+
+func (connection *postgresConnection) listen(ctx context.Context, notify func(lobbyId uuid.UUID), invalidatePlayer func(playerId uuid.UUID)) error {
+	conn, err := pgx.Connect(ctx, connection.url)
+	if err != nil {
+		return fmt.Errorf("unable to connect to database: %v", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, listen_message_sql); err != nil {
+		return fmt.Errorf("error while listening to channel %s: %v", message_channel, err)
+	}
+	if _, err := conn.Exec(ctx, listen_player_sql); err != nil {
+		return fmt.Errorf("error while listening to channel %s: %v", player_channel, err)
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("error while waiting for notification: %v", err)
+		}
+		id, err := uuid.Parse(notification.Payload)
+		if err != nil {
+			log.Warnf("Notification with unknown payload %s received: %v", notification.Payload, err)
+			continue
+		}
+		if notification.Channel == player_channel {
+			invalidatePlayer(id)
+		} else {
+			notify(id)
+		}
+	}
+}
+
+func (tx *postgresTransaction) notifyMessage(lobbyId uuid.UUID) error {
+	if _, err := tx.tx.Exec(context.Background(), notify_message_sql, message_channel, lobbyId.String()); err != nil {
+		return fmt.Errorf("unknown error when notifying about message: %v", err)
+	}
+	return nil
+}
+
+// NotifyPlayerInvalidated lets all instances of the service invalidate the player in their cache.
+func (connection *postgresConnection) NotifyPlayerInvalidated(playerId uuid.UUID) error {
+	if _, err := connection.dbPool.Exec(context.Background(), notify_message_sql, player_channel, playerId.String()); err != nil {
+		return fmt.Errorf("unknown error when notifying about invalidated player: %v", err)
+	}
+	return nil
+}
@@ -14,18 +14,53 @@ import (
 
 const (
 	message_table_name                  = "message"
-	create_message_sql                  = "INSERT INTO %s.%s(id, send_time, lobby_id, player_id, topic, message) VALUES($1, $2, $3, $4, $5, $6)"
-	select_messages_by_lobby_and_number = "SELECT id, send_time, lobby_id, player_id, number, topic, message FROM %s.%s WHERE lobby_id = $1 AND player_id != $2 AND number > $3"
-	select_first_messages_of_player     = "SELECT id, send_time, lobby_id, player_id, number, topic, message FROM %s.%s WHERE lobby_id = $1 AND player_id != $2 AND number > (SELECT number FROM %s.%s WHERE lobby_id = $1 AND player_id = $2 AND topic = 'PLAYER_JOINS_LOBBY' ORDER BY number DESC LIMIT 1)"
-	delete_messages_by_older_then       = "DELETE FROM %s.%s WHERE send_time < $1"
+	message_recipient_table_name        = "message_recipient"
+	message_revision_table_name         = "message_revision"
+	lobby_sequence_table_name           = "lobby_sequence"
+	select_message_exists               = "SELECT EXISTS(SELECT 1 FROM %s.%s WHERE id = $1)"
+	next_lobby_number_sql               = "INSERT INTO %s.%s AS seq(lobby_id, number) VALUES($1, 1) ON CONFLICT (lobby_id) DO UPDATE SET number = seq.number + 1 RETURNING number"
+	create_message_sql                  = "INSERT INTO %s.%s(id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+	select_messages_by_lobby_and_number = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM %s.%s WHERE lobby_id = $1 AND player_id != $2 AND number > $3 AND (team = '' OR team = $4) AND (NOT direct OR EXISTS(SELECT 1 FROM %s.%s AS recipient WHERE recipient.message_id = message.id AND recipient.player_id = $2)) AND (revision = 1 OR (SELECT number FROM %s.%s AS first_revision WHERE first_revision.message_id = message.id AND first_revision.revision = 1) > COALESCE((SELECT number FROM %s.%s AS joined WHERE joined.lobby_id = $1 AND joined.player_id = $2 AND joined.topic = 'PLAYER_JOINS_LOBBY' ORDER BY joined.number DESC LIMIT 1), 0)) ORDER BY number"
+	select_first_messages_of_player     = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM %s.%s WHERE lobby_id = $1 AND player_id != $2 AND number > (SELECT number FROM %s.%s AS joined WHERE joined.lobby_id = $1 AND joined.player_id = $2 AND joined.topic = 'PLAYER_JOINS_LOBBY' ORDER BY joined.number DESC LIMIT 1) AND (team = '' OR team = $3) AND (NOT direct OR EXISTS(SELECT 1 FROM %s.%s AS recipient WHERE recipient.message_id = message.id AND recipient.player_id = $2)) AND (revision = 1 OR (SELECT number FROM %s.%s AS first_revision WHERE first_revision.message_id = message.id AND first_revision.revision = 1) > (SELECT number FROM %s.%s AS joined WHERE joined.lobby_id = $1 AND joined.player_id = $2 AND joined.topic = 'PLAYER_JOINS_LOBBY' ORDER BY joined.number DESC LIMIT 1)) ORDER BY number"
+	select_message_by_id                = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM %s.%s WHERE lobby_id = $1 AND id = $2 FOR UPDATE"
+	create_message_revision_sql         = "INSERT INTO %s.%s(message_id, revision, number, message) SELECT id, revision, number, message FROM %s.%s WHERE id = $1"
+	update_message_sql                  = "UPDATE %s.%s SET message = $2, number = $3, revision = revision + 1 WHERE id = $1 RETURNING revision"
+	create_message_recipient_sql        = "INSERT INTO %s.%s(message_id, player_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+	select_messages_by_older_then       = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM %s.%s WHERE send_time < $1 AND NOT (topic = ANY($2)) ORDER BY send_time LIMIT $3"
+	select_messages_by_lobby            = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM %s.%s WHERE lobby_id = $1 ORDER BY number LIMIT $2"
+	select_topic_messages_by_older_then = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM %s.%s WHERE topic = $1 AND send_time < $2 ORDER BY send_time LIMIT $3"
+	delete_messages_by_older_then       = "DELETE FROM %s.%s WHERE send_time < $1 AND NOT (topic = ANY($2))"
+	delete_messages_by_lobby            = "DELETE FROM %s.%s WHERE lobby_id = $1"
+	delete_topic_messages_by_older_then = "DELETE FROM %s.%s WHERE topic = $1 AND send_time < $2"
+	lock_lobby_sequence_sql             = "SELECT number FROM %s.%s WHERE lobby_id = $1 FOR UPDATE"
+	delete_empty_lobby_sequence_sql     = "DELETE FROM %s.%s WHERE lobby_id = $1 AND NOT EXISTS(SELECT 1 FROM %s.%s WHERE lobby_id = $1)"
+	select_message_revisions_by_id      = "SELECT message_id, revision, number, message FROM %s.%s WHERE message_id = ANY($1) ORDER BY message_id, revision"
+	delete_messages_by_id               = "DELETE FROM %s.%s WHERE id = ANY($1)"
 )
 
 var (
 	ErrMessageAlreadyExists = errors.New("message already exists")
+	ErrMessageNotFound      = errors.New("message not found")
 )
 
+// CreateMessage assigns the next number of the lobby to the message. The row of the lobby sequence stays locked until the transaction ends,
+// so numbers of a lobby are committed in order and only edited messages leave gaps.
 func (tx *postgresTransaction) CreateMessage(message *Message) error {
-	if _, err := tx.tx.Exec(context.Background(), fmt.Sprintf(create_message_sql, schema_name, message_table_name), message.ID, message.SendTime, message.LobbyId, message.PlayerId, message.Topic, message.Message); err != nil {
+	message.Direct = len(message.Recipients) > 0
+	var exists bool
+	if err := tx.tx.QueryRow(context.Background(), fmt.Sprintf(select_message_exists, schema_name, message_table_name), message.ID).Scan(&exists); err != nil {
+		return fmt.Errorf("unknown error when checking if message exists: %v", err)
+	}
+	if exists {
+		return ErrMessageAlreadyExists
+	}
+
+	var number int
+	if err := tx.tx.QueryRow(context.Background(), fmt.Sprintf(next_lobby_number_sql, schema_name, lobby_sequence_table_name), message.LobbyId).Scan(&number); err != nil {
+		return fmt.Errorf("unknown error when getting next number of lobby: %v", err)
+	}
+
+	if _, err := tx.tx.Exec(context.Background(), fmt.Sprintf(create_message_sql, schema_name, message_table_name), message.ID, message.SendTime, message.LobbyId, message.PlayerId, number, message.Topic, message.Message, message.Direct, message.Channel, message.Team); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			switch pgErr.Code {
@@ -36,30 +71,162 @@ func (tx *postgresTransaction) CreateMessage(message *Message) error {
 
 		return fmt.Errorf("unknown error when inserting message: %v", err)
 	}
-	return nil
+	for _, recipient := range message.Recipients {
+		if _, err := tx.tx.Exec(context.Background(), fmt.Sprintf(create_message_recipient_sql, schema_name, message_recipient_table_name), message.ID, recipient); err != nil {
+			return fmt.Errorf("unknown error when inserting recipient of message: %v", err)
+		}
+	}
+	message.Number = number
+	return tx.notifyMessage(message.LobbyId)
+}
+
+// GetMessage locks the message until the transaction ends.
+func (tx *postgresTransaction) GetMessage(lobbyId uuid.UUID, messageId uuid.UUID) (*Message, error) {
+	message := new(Message)
+	if err := pgxscan.Get(context.Background(), tx.tx, message, fmt.Sprintf(select_message_by_id, schema_name, message_table_name), lobbyId, messageId); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("error while selecting message: %v", err)
+	}
+	return message, nil
+}
+
+// EditMessage keeps the stored content as revision and replaces it with the content of the message. The message gets the next number of its lobby,
+// so the old number is left as gap. Clients can not tell such a gap from a message they are not allowed to see, gaps are never a sign of loss.
+func (tx *postgresTransaction) EditMessage(message *Message) error {
+	var number int
+	if err := tx.tx.QueryRow(context.Background(), fmt.Sprintf(next_lobby_number_sql, schema_name, lobby_sequence_table_name), message.LobbyId).Scan(&number); err != nil {
+		return fmt.Errorf("unknown error when getting next number of lobby: %v", err)
+	}
+
+	result, err := tx.tx.Exec(context.Background(), fmt.Sprintf(create_message_revision_sql, schema_name, message_revision_table_name, schema_name, message_table_name), message.ID)
+	if err != nil {
+		return fmt.Errorf("unknown error when inserting revision of message: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrMessageNotFound
+	}
+
+	var revision int
+	if err := tx.tx.QueryRow(context.Background(), fmt.Sprintf(update_message_sql, schema_name, message_table_name), message.ID, message.Message, number).Scan(&revision); err != nil {
+		return fmt.Errorf("unknown error when updating message: %v", err)
+	}
+	message.Number = number
+	message.Revision = revision
+	return tx.notifyMessage(message.LobbyId)
 }
 
-func (tx *postgresTransaction) GetMessages(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, number int) ([]*Message, error) {
+// GetMessages loads the messages after number which are visible to the player. An edited message is only visible if the player joined the lobby
+// before its first revision, otherwise the edit would pass the PLAYER_JOINS_LOBBY message and reveal older messages.
+func (tx *postgresTransaction) GetMessages(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, team string, number int) ([]*Message, error) {
 	var messages []*Message
-	if err := pgxscan.Select(context.Background(), tx.tx, &messages, fmt.Sprintf(select_messages_by_lobby_and_number, schema_name, message_table_name), lobbyId, toIgnoreplayerId, number); err != nil {
+	if err := pgxscan.Select(context.Background(), tx.tx, &messages, fmt.Sprintf(select_messages_by_lobby_and_number, schema_name, message_table_name, schema_name, message_recipient_table_name, schema_name, message_revision_table_name, schema_name, message_table_name), lobbyId, toIgnoreplayerId, number, team); err != nil {
 		return nil, fmt.Errorf("error while selecting all messages: %v", err)
 	}
 
 	return messages, nil
 }
 
-func (tx *postgresTransaction) GetMessagesFirstRequest(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID) ([]*Message, error) {
+func (tx *postgresTransaction) GetMessagesFirstRequest(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, team string) ([]*Message, error) {
 	var messages []*Message
-	if err := pgxscan.Select(context.Background(), tx.tx, &messages, fmt.Sprintf(select_first_messages_of_player, schema_name, message_table_name, schema_name, message_table_name), lobbyId, toIgnoreplayerId); err != nil {
+	if err := pgxscan.Select(context.Background(), tx.tx, &messages, fmt.Sprintf(select_first_messages_of_player, schema_name, message_table_name, schema_name, message_table_name, schema_name, message_recipient_table_name, schema_name, message_revision_table_name, schema_name, message_table_name), lobbyId, toIgnoreplayerId, team); err != nil {
 		return nil, fmt.Errorf("error while selecting first messages: %v", err)
 	}
 
 	return messages, nil
 }
 
-func (tx *postgresTransaction) DeleteMessages(time time.Time) error {
-	if _, err := tx.tx.Exec(context.Background(), fmt.Sprintf(delete_messages_by_older_then, schema_name, message_table_name), time); err != nil {
-		return fmt.Errorf("unknown error when deliting messages: %v", err)
+// GetMessagesOlderThan loads up to limit messages sent before time, except the messages of the topics to keep. The oldest messages come first.
+func (tx *postgresTransaction) GetMessagesOlderThan(time time.Time, keepTopics []string, limit int) ([]*Message, error) {
+	if keepTopics == nil {
+		keepTopics = []string{}
+	}
+	var messages []*Message
+	if err := pgxscan.Select(context.Background(), tx.tx, &messages, fmt.Sprintf(select_messages_by_older_then, schema_name, message_table_name), time, keepTopics, limit); err != nil {
+		return nil, fmt.Errorf("error while selecting old messages: %v", err)
+	}
+	return messages, nil
+}
+
+func (tx *postgresTransaction) GetTopicMessagesOlderThan(topic string, time time.Time, limit int) ([]*Message, error) {
+	var messages []*Message
+	if err := pgxscan.Select(context.Background(), tx.tx, &messages, fmt.Sprintf(select_topic_messages_by_older_then, schema_name, message_table_name), topic, time, limit); err != nil {
+		return nil, fmt.Errorf("error while selecting old messages of topic %s: %v", topic, err)
+	}
+	return messages, nil
+}
+
+func (tx *postgresTransaction) GetLobbyMessages(lobbyId uuid.UUID, limit int) ([]*Message, error) {
+	var messages []*Message
+	if err := pgxscan.Select(context.Background(), tx.tx, &messages, fmt.Sprintf(select_messages_by_lobby, schema_name, message_table_name), lobbyId, limit); err != nil {
+		return nil, fmt.Errorf("error while selecting messages of lobby %v: %v", lobbyId, err)
+	}
+	return messages, nil
+}
+
+// DeleteMessages deletes all messages sent before time, except the messages of the topics to keep. The number of deleted messages is returned.
+func (tx *postgresTransaction) DeleteMessages(time time.Time, keepTopics []string) (int64, error) {
+	if keepTopics == nil {
+		keepTopics = []string{}
+	}
+	result, err := tx.tx.Exec(context.Background(), fmt.Sprintf(delete_messages_by_older_then, schema_name, message_table_name), time, keepTopics)
+	if err != nil {
+		return 0, fmt.Errorf("unknown error when deliting messages: %v", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+func (tx *postgresTransaction) DeleteTopicMessages(topic string, time time.Time) (int64, error) {
+	result, err := tx.tx.Exec(context.Background(), fmt.Sprintf(delete_topic_messages_by_older_then, schema_name, message_table_name), topic, time)
+	if err != nil {
+		return 0, fmt.Errorf("unknown error when deliting messages of topic %s: %v", topic, err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// DeleteLobbyMessages deletes all messages of the lobby. The sequence of the lobby is removed by DeleteLobbySequence.
+func (tx *postgresTransaction) DeleteLobbyMessages(lobbyId uuid.UUID) (int64, error) {
+	result, err := tx.tx.Exec(context.Background(), fmt.Sprintf(delete_messages_by_lobby, schema_name, message_table_name), lobbyId)
+	if err != nil {
+		return 0, fmt.Errorf("unknown error when deliting messages of lobby %v: %v", lobbyId, err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// DeleteLobbySequence removes the sequence of a lobby without messages, so closed lobbies leave nothing behind. The sequence is locked before
+// checking for messages, a message created concurrently keeps the sequence.
+func (tx *postgresTransaction) DeleteLobbySequence(lobbyId uuid.UUID) error {
+	if _, err := tx.tx.Exec(context.Background(), fmt.Sprintf(lock_lobby_sequence_sql, schema_name, lobby_sequence_table_name), lobbyId); err != nil {
+		return fmt.Errorf("unknown error when locking sequence of lobby %v: %v", lobbyId, err)
+	}
+	if _, err := tx.tx.Exec(context.Background(), fmt.Sprintf(delete_empty_lobby_sequence_sql, schema_name, lobby_sequence_table_name, schema_name, message_table_name), lobbyId); err != nil {
+		return fmt.Errorf("unknown error when deleting sequence of lobby %v: %v", lobbyId, err)
 	}
 	return nil
 }
+
+func (tx *postgresTransaction) DeleteMessagesById(messageIds []uuid.UUID) (int64, error) {
+	result, err := tx.tx.Exec(context.Background(), fmt.Sprintf(delete_messages_by_id, schema_name, message_table_name), mapToIds(messageIds))
+	if err != nil {
+		return 0, fmt.Errorf("unknown error when deliting messages by id: %v", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// GetMessageRevisions loads the replaced contents of the messages ordered by message and revision.
+func (tx *postgresTransaction) GetMessageRevisions(messageIds []uuid.UUID) ([]*MessageRevision, error) {
+	var revisions []*MessageRevision
+	if err := pgxscan.Select(context.Background(), tx.tx, &revisions, fmt.Sprintf(select_message_revisions_by_id, schema_name, message_revision_table_name), mapToIds(messageIds)); err != nil {
+		return nil, fmt.Errorf("error while selecting revisions of messages: %v", err)
+	}
+	return revisions, nil
+}
+
+func mapToIds(messageIds []uuid.UUID) []string {
+	ids := make([]string, len(messageIds))
+	for index, messageId := range messageIds {
+		ids[index] = messageId.String()
+	}
+	return ids
+}
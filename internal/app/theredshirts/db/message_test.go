@@ -0,0 +1,503 @@
+package db
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConnections(t *testing.T) map[string]DB {
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "test.db"))
+	sqliteConnection, err := newSqliteConnection()
+	assert.Nil(t, err)
+	t.Cleanup(sqliteConnection.Close)
+	return map[string]DB{"inmemory": newInMemoryConnection(), "sqlite": sqliteConnection}
+}
+
+func createTestMessage(t *testing.T, tx DBTx, lobbyId uuid.UUID, playerId uuid.UUID, topic string, sendTime time.Time) *Message {
+	message := &Message{ID: uuid.New(), SendTime: sendTime, LobbyId: lobbyId, PlayerId: playerId, Topic: topic, Message: map[string]interface{}{"text": "some text"}}
+	err := tx.CreateMessage(message)
+	assert.Nil(t, err)
+	return message
+}
+
+func TestCreateMessage_NumberPerLobby(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			someLobbyId := uuid.New()
+			otherLobbyId := uuid.New()
+
+			first := createTestMessage(t, tx, someLobbyId, uuid.New(), "CHAT", time.Now())
+			second := createTestMessage(t, tx, someLobbyId, uuid.New(), "CHAT", time.Now())
+			other := createTestMessage(t, tx, otherLobbyId, uuid.New(), "CHAT", time.Now())
+
+			assert.Equal(t, 1, first.Number)
+			assert.Equal(t, 2, second.Number)
+			assert.Equal(t, 1, other.Number)
+			assert.Nil(t, tx.Commit())
+		})
+	}
+}
+
+func TestCreateMessage_AlreadyExists(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			message := createTestMessage(t, tx, uuid.New(), uuid.New(), "CHAT", time.Now())
+
+			err := tx.CreateMessage(&Message{ID: message.ID, LobbyId: message.LobbyId, PlayerId: message.PlayerId})
+			assert.ErrorIs(t, err, ErrMessageAlreadyExists)
+		})
+	}
+}
+
+func TestCreateMessage_Rollback(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+
+			tx, _ := connection.StartTransaction()
+			createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+			assert.Nil(t, tx.Rollback())
+
+			tx, _ = connection.StartTransaction()
+			defer tx.Rollback()
+			message := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+			assert.Equal(t, 1, message.Number)
+		})
+	}
+}
+
+func TestGetMessages_IgnoreOwnMessages(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+			otherPlayerId := uuid.New()
+
+			createTestMessage(t, tx, someLobbyId, otherPlayerId, "CHAT", time.Now())
+			createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+			third := createTestMessage(t, tx, someLobbyId, otherPlayerId, "CHAT", time.Now())
+			createTestMessage(t, tx, uuid.New(), otherPlayerId, "CHAT", time.Now())
+
+			messages, err := tx.GetMessages(someLobbyId, somePlayerId, "", 1)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 1)
+			assert.Equal(t, third.ID, messages[0].ID)
+		})
+	}
+}
+
+func TestGetMessages_OnlyRecipientsOfDirectMessages(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+			otherPlayerId := uuid.New()
+			recipientId := uuid.New()
+
+			direct := &Message{ID: uuid.New(), SendTime: time.Now(), LobbyId: someLobbyId, PlayerId: somePlayerId, Topic: "CHAT", Message: map[string]interface{}{}, Recipients: []uuid.UUID{recipientId, recipientId}}
+			assert.Nil(t, tx.CreateMessage(direct))
+			broadcast := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+
+			messages, err := tx.GetMessages(someLobbyId, recipientId, "", 0)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 2)
+			assert.Equal(t, direct.ID, messages[0].ID)
+			assert.True(t, messages[0].Direct)
+			assert.False(t, messages[1].Direct)
+
+			messages, err = tx.GetMessages(someLobbyId, otherPlayerId, "", 0)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 1)
+			assert.Equal(t, broadcast.ID, messages[0].ID)
+		})
+	}
+}
+
+func TestGetMessages_OnlyTeamOfTeamMessages(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+
+			team := &Message{ID: uuid.New(), SendTime: time.Now(), LobbyId: someLobbyId, PlayerId: somePlayerId, Topic: "CHAT", Message: map[string]interface{}{}, Channel: "team", Team: "red"}
+			assert.Nil(t, tx.CreateMessage(team))
+			lobby := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+
+			messages, err := tx.GetMessages(someLobbyId, uuid.New(), "red", 0)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 2)
+			assert.Equal(t, team.ID, messages[0].ID)
+			assert.Equal(t, "team", messages[0].Channel)
+			assert.Equal(t, "red", messages[0].Team)
+
+			messages, err = tx.GetMessages(someLobbyId, uuid.New(), "blue", 0)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 1)
+			assert.Equal(t, lobby.ID, messages[0].ID)
+		})
+	}
+}
+
+func TestGetMessagesFirstRequest_AfterLatestJoin(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+			otherPlayerId := uuid.New()
+
+			createTestMessage(t, tx, someLobbyId, somePlayerId, player_joins_lobby_topic, time.Now())
+			createTestMessage(t, tx, someLobbyId, otherPlayerId, "CHAT", time.Now())
+			createTestMessage(t, tx, someLobbyId, somePlayerId, player_joins_lobby_topic, time.Now())
+			last := createTestMessage(t, tx, someLobbyId, otherPlayerId, "CHAT", time.Now())
+
+			messages, err := tx.GetMessagesFirstRequest(someLobbyId, somePlayerId, "")
+			assert.Nil(t, err)
+			assert.Len(t, messages, 1)
+			assert.Equal(t, last.ID, messages[0].ID)
+		})
+	}
+}
+
+func TestGetMessagesFirstRequest_NotJoined(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			someLobbyId := uuid.New()
+
+			createTestMessage(t, tx, someLobbyId, uuid.New(), "CHAT", time.Now())
+
+			messages, err := tx.GetMessagesFirstRequest(someLobbyId, uuid.New(), "")
+			assert.Nil(t, err)
+			assert.Empty(t, messages)
+		})
+	}
+}
+
+func TestEditMessage_NextNumber(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+			edited := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+			other := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+
+			message, err := tx.GetMessage(someLobbyId, edited.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, message.Revision)
+			message.Message = map[string]interface{}{"text": "edited text"}
+			assert.Nil(t, tx.EditMessage(message))
+			assert.Equal(t, 3, message.Number)
+			assert.Equal(t, 2, message.Revision)
+
+			messages, err := tx.GetMessages(someLobbyId, uuid.New(), "", 0)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 2)
+			assert.Equal(t, other.ID, messages[0].ID)
+			assert.Equal(t, edited.ID, messages[1].ID)
+			assert.Equal(t, 3, messages[1].Number)
+			assert.Equal(t, 2, messages[1].Revision)
+			assert.Equal(t, "edited text", messages[1].Message["text"])
+		})
+	}
+}
+
+func TestEditMessage_KeepsRevision(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			message := createTestMessage(t, tx, uuid.New(), uuid.New(), "CHAT", time.Now())
+			message.Message = map[string]interface{}{"text": "edited text"}
+			assert.Nil(t, tx.EditMessage(message))
+
+			var revision, number int
+			var content string
+			switch connection := connection.(type) {
+			case *inMemoryConnection:
+				assert.Len(t, connection.revisions[message.ID], 1)
+				revision, number = connection.revisions[message.ID][0].Revision, connection.revisions[message.ID][0].Number
+				content, _ = connection.revisions[message.ID][0].Message["text"].(string)
+			case *sqliteConnection:
+				var stored string
+				err := tx.(*sqliteTransaction).tx.QueryRow("SELECT revision, number, message FROM message_revision WHERE message_id = ?1", message.ID).Scan(&revision, &number, &stored)
+				assert.Nil(t, err)
+				var decoded map[string]interface{}
+				assert.Nil(t, json.Unmarshal([]byte(stored), &decoded))
+				content, _ = decoded["text"].(string)
+			}
+			assert.Equal(t, 1, revision)
+			assert.Equal(t, 1, number)
+			assert.Equal(t, "some text", content)
+		})
+	}
+}
+
+func TestEditMessage_HiddenFromLaterJoiners(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			someLobbyId := uuid.New()
+			earlyPlayerId := uuid.New()
+			latePlayerId := uuid.New()
+			createTestMessage(t, tx, someLobbyId, earlyPlayerId, player_joins_lobby_topic, time.Now())
+			edited := createTestMessage(t, tx, someLobbyId, uuid.New(), "CHAT", time.Now())
+			join := createTestMessage(t, tx, someLobbyId, latePlayerId, player_joins_lobby_topic, time.Now())
+
+			edited.Message = map[string]interface{}{"text": "edited text"}
+			assert.Nil(t, tx.EditMessage(edited))
+
+			messages, err := tx.GetMessagesFirstRequest(someLobbyId, latePlayerId, "")
+			assert.Nil(t, err)
+			assert.Empty(t, messages)
+			messages, err = tx.GetMessages(someLobbyId, latePlayerId, "", join.Number)
+			assert.Nil(t, err)
+			assert.Empty(t, messages)
+
+			messages, err = tx.GetMessages(someLobbyId, earlyPlayerId, "", join.Number)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 1)
+			assert.Equal(t, edited.ID, messages[0].ID)
+			messages, err = tx.GetMessagesFirstRequest(someLobbyId, earlyPlayerId, "")
+			assert.Nil(t, err)
+			assert.Len(t, messages, 2)
+		})
+	}
+}
+
+func TestGetMessageRevisions_EditedMessages(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			someLobbyId := uuid.New()
+			edited := createTestMessage(t, tx, someLobbyId, uuid.New(), "CHAT", time.Now())
+			unedited := createTestMessage(t, tx, someLobbyId, uuid.New(), "CHAT", time.Now())
+			for _, text := range []string{"second", "third"} {
+				edited.Message = map[string]interface{}{"text": text}
+				assert.Nil(t, tx.EditMessage(edited))
+			}
+
+			revisions, err := tx.GetMessageRevisions([]uuid.UUID{edited.ID, unedited.ID})
+			assert.Nil(t, err)
+			assert.Len(t, revisions, 2)
+			assert.Equal(t, edited.ID, revisions[0].MessageId)
+			assert.Equal(t, 1, revisions[0].Revision)
+			assert.Equal(t, 1, revisions[0].Number)
+			assert.Equal(t, "some text", revisions[0].Message["text"])
+			assert.Equal(t, 2, revisions[1].Revision)
+			assert.Equal(t, 3, revisions[1].Number)
+			assert.Equal(t, "second", revisions[1].Message["text"])
+		})
+	}
+}
+
+func TestGetMessage_NotFound(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, _ := connection.StartTransaction()
+			defer tx.Rollback()
+			message := createTestMessage(t, tx, uuid.New(), uuid.New(), "CHAT", time.Now())
+
+			_, err := tx.GetMessage(uuid.New(), message.ID)
+			assert.ErrorIs(t, err, ErrMessageNotFound)
+			_, err = tx.GetMessage(message.LobbyId, uuid.New())
+			assert.ErrorIs(t, err, ErrMessageNotFound)
+		})
+	}
+}
+
+func TestDeleteMessages_OlderThan(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+
+			tx, _ := connection.StartTransaction()
+			createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now().Add(-time.Hour))
+			newMessage := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			deleted, err := tx.DeleteMessages(time.Now().Add(-time.Minute), nil)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(1), deleted)
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			defer tx.Rollback()
+			messages, err := tx.GetMessages(someLobbyId, uuid.New(), "", 0)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 1)
+			assert.Equal(t, newMessage.ID, messages[0].ID)
+		})
+	}
+}
+
+func TestGetMessagesOlderThan_DeleteById(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+
+			tx, _ := connection.StartTransaction()
+			oldest := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now().Add(-2*time.Hour))
+			old := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now().Add(-time.Hour))
+			createTestMessage(t, tx, someLobbyId, somePlayerId, player_joins_lobby_topic, time.Now().Add(-time.Hour))
+			createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			messages, err := tx.GetMessagesOlderThan(time.Now().Add(-time.Minute), []string{player_joins_lobby_topic}, 1)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 1)
+			assert.Equal(t, oldest.ID, messages[0].ID)
+			messages, err = tx.GetMessagesOlderThan(time.Now().Add(-time.Minute), []string{player_joins_lobby_topic}, 10)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 2)
+
+			deleted, err := tx.DeleteMessagesById([]uuid.UUID{oldest.ID, old.ID})
+			assert.Nil(t, err)
+			assert.Equal(t, int64(2), deleted)
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			defer tx.Rollback()
+			messages, err = tx.GetLobbyMessages(someLobbyId, 10)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 2)
+		})
+	}
+}
+
+func TestDeleteMessages_KeepTopics(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+
+			tx, _ := connection.StartTransaction()
+			createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now().Add(-time.Hour))
+			joinMessage := createTestMessage(t, tx, someLobbyId, somePlayerId, player_joins_lobby_topic, time.Now().Add(-time.Hour))
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			deleted, err := tx.DeleteMessages(time.Now().Add(-time.Minute), []string{player_joins_lobby_topic})
+			assert.Nil(t, err)
+			assert.Equal(t, int64(1), deleted)
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			defer tx.Rollback()
+			messages, err := tx.GetMessages(someLobbyId, uuid.New(), "", 0)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 1)
+			assert.Equal(t, joinMessage.ID, messages[0].ID)
+		})
+	}
+}
+
+func TestDeleteTopicMessages_OnlyTopic(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			someLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+
+			tx, _ := connection.StartTransaction()
+			chatMessage := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now().Add(-time.Hour))
+			createTestMessage(t, tx, someLobbyId, somePlayerId, "STATE", time.Now().Add(-time.Hour))
+			createTestMessage(t, tx, someLobbyId, somePlayerId, "STATE", time.Now())
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			deleted, err := tx.DeleteTopicMessages("STATE", time.Now().Add(-time.Minute))
+			assert.Nil(t, err)
+			assert.Equal(t, int64(1), deleted)
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			defer tx.Rollback()
+			messages, err := tx.GetMessages(someLobbyId, uuid.New(), "", 0)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 2)
+			assert.Equal(t, chatMessage.ID, messages[0].ID)
+		})
+	}
+}
+
+func TestDeleteLobbyMessages_RemoveSequence(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			someLobbyId := uuid.New()
+			otherLobbyId := uuid.New()
+			somePlayerId := uuid.New()
+
+			tx, _ := connection.StartTransaction()
+			createTestMessage(t, tx, someLobbyId, somePlayerId, player_joins_lobby_topic, time.Now())
+			createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+			createTestMessage(t, tx, otherLobbyId, somePlayerId, "CHAT", time.Now())
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			deleted, err := tx.DeleteLobbyMessages(someLobbyId)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(2), deleted)
+			assert.Nil(t, tx.DeleteLobbySequence(someLobbyId))
+			assert.Nil(t, tx.DeleteLobbySequence(otherLobbyId))
+			assert.Nil(t, tx.Commit())
+
+			tx, _ = connection.StartTransaction()
+			defer tx.Rollback()
+			messages, err := tx.GetMessages(someLobbyId, uuid.New(), "", 0)
+			assert.Nil(t, err)
+			assert.Empty(t, messages)
+			messages, err = tx.GetMessages(otherLobbyId, uuid.New(), "", 0)
+			assert.Nil(t, err)
+			assert.Len(t, messages, 1)
+			message := createTestMessage(t, tx, someLobbyId, somePlayerId, "CHAT", time.Now())
+			assert.Equal(t, 1, message.Number)
+			message = createTestMessage(t, tx, otherLobbyId, somePlayerId, "CHAT", time.Now())
+			assert.Equal(t, 2, message.Number)
+		})
+	}
+}
+
+func TestCheckMigration_Migrated(t *testing.T) {
+	for name, connection := range testConnections(t) {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, connection.Ping())
+			assert.Nil(t, connection.CheckMigration())
+		})
+	}
+}
+
+func TestCheckMigration_CheckedAtStartup(t *testing.T) {
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "test.db"))
+	connection, err := newSqliteConnection()
+	assert.Nil(t, err)
+	defer connection.Close()
+
+	_, err = connection.(*sqliteConnection).db.Exec("UPDATE theredshirts_message SET dirty = 1")
+	assert.Nil(t, err)
+	assert.Nil(t, connection.CheckMigration())
+}
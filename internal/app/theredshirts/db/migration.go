@@ -0,0 +1,62 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// checkMigration fails if the last migration of the database failed or the database is behind the migrations embedded in this service.
+// A database migrated further by a newer instance is fine.
+func checkMigration(migrationFs fs.FS, path string, url string) error {
+	driver, err := iofs.New(migrationFs, path)
+	if err != nil {
+		return fmt.Errorf("error while creating instance of migration scrips: %v", err)
+	}
+	expectedVersion, err := lastMigrationVersion(driver)
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", driver, url)
+	if err != nil {
+		return fmt.Errorf("error while creating instance of migration scrips: %v", err)
+	}
+	defer m.Close()
+	version, dirty, err := m.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return fmt.Errorf("database is not migrated")
+		}
+		return fmt.Errorf("error while loading migration version: %v", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d of database failed", version)
+	}
+	if version < expectedVersion {
+		return fmt.Errorf("database is at migration %d but %d is expected", version, expectedVersion)
+	}
+	return nil
+}
+
+func lastMigrationVersion(driver source.Driver) (uint, error) {
+	version, err := driver.First()
+	if err != nil {
+		return 0, fmt.Errorf("error while loading first migration: %v", err)
+	}
+	for {
+		next, err := driver.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error while loading migration after %d: %v", version, err)
+		}
+		version = next
+	}
+}
@@ -0,0 +1,16 @@
+CREATE TABLE theredshirts_message.lobby_sequence (
+    lobby_id uuid PRIMARY KEY NOT NULL,
+    number integer NOT NULL
+);
+
+ALTER TABLE theredshirts_message.message ALTER COLUMN number DROP DEFAULT;
+DROP SEQUENCE IF EXISTS theredshirts_message.message_number_seq;
+
+UPDATE theredshirts_message.message AS message SET number = numbered.number
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY lobby_id ORDER BY number) AS number FROM theredshirts_message.message) AS numbered
+WHERE message.id = numbered.id;
+
+INSERT INTO theredshirts_message.lobby_sequence (lobby_id, number)
+SELECT lobby_id, MAX(number) FROM theredshirts_message.message GROUP BY lobby_id;
+
+ALTER TABLE theredshirts_message.message ADD CONSTRAINT message_lobby_number_unique UNIQUE (lobby_id, number);
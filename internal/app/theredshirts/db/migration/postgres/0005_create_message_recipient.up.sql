@@ -0,0 +1,7 @@
+ALTER TABLE theredshirts_message.message ADD COLUMN direct boolean NOT NULL DEFAULT false;
+
+CREATE TABLE theredshirts_message.message_recipient (
+    message_id uuid NOT NULL REFERENCES theredshirts_message.message (id) ON DELETE CASCADE,
+    player_id uuid NOT NULL,
+    PRIMARY KEY (message_id, player_id)
+);
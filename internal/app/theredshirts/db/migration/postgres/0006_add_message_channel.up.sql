@@ -0,0 +1,2 @@
+ALTER TABLE theredshirts_message.message ADD COLUMN channel varchar NOT NULL DEFAULT 'lobby';
+ALTER TABLE theredshirts_message.message ADD COLUMN team varchar NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+ALTER TABLE theredshirts_message.message ADD COLUMN revision integer NOT NULL DEFAULT 1;
+
+CREATE TABLE theredshirts_message.message_revision (
+    message_id uuid NOT NULL REFERENCES theredshirts_message.message (id) ON DELETE CASCADE,
+    revision integer NOT NULL,
+    number integer NOT NULL,
+    message json NOT NULL,
+    PRIMARY KEY (message_id, revision)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE message (
+    id TEXT PRIMARY KEY NOT NULL,
+    send_time INTEGER NOT NULL,
+    lobby_id TEXT NOT NULL,
+    player_id TEXT NOT NULL,
+    number INTEGER NOT NULL,
+    topic TEXT NOT NULL,
+    message TEXT NOT NULL,
+    UNIQUE (lobby_id, number)
+);
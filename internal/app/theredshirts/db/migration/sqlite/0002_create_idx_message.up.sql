@@ -0,0 +1,2 @@
+CREATE INDEX messages_idx ON message (lobby_id, player_id, number DESC);
+CREATE INDEX messages_time_idx ON message (send_time);
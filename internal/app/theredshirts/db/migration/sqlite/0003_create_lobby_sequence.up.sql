@@ -0,0 +1,4 @@
+CREATE TABLE lobby_sequence (
+    lobby_id TEXT PRIMARY KEY NOT NULL,
+    number INTEGER NOT NULL
+);
@@ -0,0 +1,7 @@
+ALTER TABLE message ADD COLUMN direct INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE message_recipient (
+    message_id TEXT NOT NULL REFERENCES message (id) ON DELETE CASCADE,
+    player_id TEXT NOT NULL,
+    PRIMARY KEY (message_id, player_id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE message ADD COLUMN channel TEXT NOT NULL DEFAULT 'lobby';
+ALTER TABLE message ADD COLUMN team TEXT NOT NULL DEFAULT '';
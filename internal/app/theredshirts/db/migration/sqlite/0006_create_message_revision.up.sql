@@ -0,0 +1,9 @@
+ALTER TABLE message ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE message_revision (
+    message_id TEXT NOT NULL REFERENCES message (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    number INTEGER NOT NULL,
+    message TEXT NOT NULL,
+    PRIMARY KEY (message_id, revision)
+);
@@ -24,6 +24,10 @@ var (
 type (
 	postgresConnection struct {
 		dbPool *pgxpool.Pool
+		url    string
+		// migration is the result of checking the migration at startup
+		migration    error
+		stopListener context.CancelFunc
 	}
 
 	postgresTransaction struct {
@@ -53,14 +57,17 @@ func newPostgresConnection() (DB, error) {
 		return nil, fmt.Errorf("error while migrating database: %v", err)
 	}
 
+	migration := checkMigration(postgresMigrationFs, "migration/postgres", url+migrationOptions)
+
 	dbPool, err := pgxpool.Connect(context.Background(), url)
 	if err != nil {
 		return nil, fmt.Errorf("unable to connect to database: %v", err)
 	}
-	return &postgresConnection{dbPool: dbPool}, nil
+	return &postgresConnection{dbPool: dbPool, url: url, migration: migration, stopListener: func() {}}, nil
 }
 
 func (connection *postgresConnection) Close() {
+	connection.stopListener()
 	connection.dbPool.Close()
 }
 
@@ -83,6 +90,17 @@ func migratePostgresDatabase(url string) error {
 	return nil
 }
 
+func (connection *postgresConnection) Ping() error {
+	pingContext, cancel := context.WithTimeout(context.Background(), ping_timeout)
+	defer cancel()
+	return connection.dbPool.Ping(pingContext)
+}
+
+// CheckMigration returns the result of checking the migration at startup, the migrations only change when the service is deployed.
+func (connection *postgresConnection) CheckMigration() error {
+	return connection.migration
+}
+
 func (db *postgresConnection) StartTransaction() (DBTx, error) {
 	tx, err := db.dbPool.BeginTx(context.Background(), pgx.TxOptions{})
 	if err != nil {
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/BeanCodeDe/TheRedShirts-Message/internal/app/theredshirts/util"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+var (
+	//go:embed migration/sqlite/*.up.sql
+	sqliteMigrationFs embed.FS
+)
+
+type (
+	sqliteConnection struct {
+		db *sql.DB
+		// migration is the result of checking the migration at startup
+		migration error
+	}
+
+	sqliteTransaction struct {
+		tx *sql.Tx
+	}
+)
+
+func newSqliteConnection() (DB, error) {
+	path := util.GetEnvWithFallback("SQLITE_PATH", "theredshirts-message.db")
+	migrationOptions := util.GetEnvWithFallback("SQLITE_MIGRATION_OPTIONS", "?x-migrations-table=theredshirts_message")
+
+	migrationUrl := "sqlite://" + path + migrationOptions
+	err := migrateSqliteDatabase(migrationUrl)
+	if err != nil {
+		return nil, fmt.Errorf("error while migrating database: %v", err)
+	}
+
+	migration := checkMigration(sqliteMigrationFs, "migration/sqlite", migrationUrl)
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %v", err)
+	}
+	// SQLite only allows one writer, a single connection serializes the transactions instead of failing them as busy.
+	db.SetMaxOpenConns(1)
+	return &sqliteConnection{db: db, migration: migration}, nil
+}
+
+func (connection *sqliteConnection) Close() {
+	connection.db.Close()
+}
+
+func migrateSqliteDatabase(url string) error {
+	d, err := iofs.New(sqliteMigrationFs, "migration/sqlite")
+	if err != nil {
+		return fmt.Errorf("error while creating instance of migration scrips: %v", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", d, url)
+	if err != nil {
+		return fmt.Errorf("error while creating instance of migration scrips: %v", err)
+	}
+	defer m.Close()
+	err = m.Up()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			return nil
+		}
+		return fmt.Errorf("error while migrating: %v", err)
+	}
+	return nil
+}
+
+func (connection *sqliteConnection) Ping() error {
+	pingContext, cancel := context.WithTimeout(context.Background(), ping_timeout)
+	defer cancel()
+	return connection.db.PingContext(pingContext)
+}
+
+// CheckMigration returns the result of checking the migration at startup, the migrations only change when the service is deployed.
+func (connection *sqliteConnection) CheckMigration() error {
+	return connection.migration
+}
+
+func (connection *sqliteConnection) StartTransaction() (DBTx, error) {
+	tx, err := connection.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("unknown error while starting transaction: %v", err)
+	}
+	return &sqliteTransaction{tx: tx}, nil
+}
+
+// ListenMessages does nothing, a SQLite database is not shared between instances of the service.
+func (connection *sqliteConnection) ListenMessages(notify func(lobbyId uuid.UUID), invalidatePlayer func(playerId uuid.UUID)) {
+}
+
+// NotifyPlayerInvalidated does nothing, a SQLite database is not shared between instances of the service.
+func (connection *sqliteConnection) NotifyPlayerInvalidated(playerId uuid.UUID) error {
+	return nil
+}
+
+func (connection *sqliteConnection) NewLeaderLock(instanceId string) LeaderLock {
+	return newLocalLeaderLock(instanceId)
+}
+
+func (tx *sqliteTransaction) Commit() error {
+	return tx.tx.Commit()
+}
+
+func (tx *sqliteTransaction) Rollback() error {
+	return tx.tx.Rollback()
+}
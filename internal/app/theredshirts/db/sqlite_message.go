@@ -0,0 +1,298 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/sqlscan"
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	sqlite_select_message_exists               = "SELECT EXISTS(SELECT 1 FROM message WHERE id = ?1)"
+	sqlite_next_lobby_number_sql               = "INSERT INTO lobby_sequence(lobby_id, number) VALUES(?1, 1) ON CONFLICT (lobby_id) DO UPDATE SET number = number + 1"
+	sqlite_select_lobby_number_sql             = "SELECT number FROM lobby_sequence WHERE lobby_id = ?1"
+	sqlite_create_message_sql                  = "INSERT INTO message(id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team) VALUES(?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10)"
+	sqlite_select_messages_by_lobby_and_number = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM message WHERE lobby_id = ?1 AND player_id != ?2 AND number > ?3 AND (team = '' OR team = ?4) AND (NOT direct OR EXISTS(SELECT 1 FROM message_recipient AS recipient WHERE recipient.message_id = message.id AND recipient.player_id = ?2)) AND (revision = 1 OR (SELECT number FROM message_revision AS first_revision WHERE first_revision.message_id = message.id AND first_revision.revision = 1) > COALESCE((SELECT number FROM message AS joined WHERE joined.lobby_id = ?1 AND joined.player_id = ?2 AND joined.topic = 'PLAYER_JOINS_LOBBY' ORDER BY joined.number DESC LIMIT 1), 0)) ORDER BY number"
+	sqlite_select_first_messages_of_player     = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM message WHERE lobby_id = ?1 AND player_id != ?2 AND number > (SELECT number FROM message AS joined WHERE joined.lobby_id = ?1 AND joined.player_id = ?2 AND joined.topic = 'PLAYER_JOINS_LOBBY' ORDER BY joined.number DESC LIMIT 1) AND (team = '' OR team = ?3) AND (NOT direct OR EXISTS(SELECT 1 FROM message_recipient AS recipient WHERE recipient.message_id = message.id AND recipient.player_id = ?2)) AND (revision = 1 OR (SELECT number FROM message_revision AS first_revision WHERE first_revision.message_id = message.id AND first_revision.revision = 1) > (SELECT number FROM message AS joined WHERE joined.lobby_id = ?1 AND joined.player_id = ?2 AND joined.topic = 'PLAYER_JOINS_LOBBY' ORDER BY joined.number DESC LIMIT 1)) ORDER BY number"
+	sqlite_select_message_by_id                = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM message WHERE lobby_id = ?1 AND id = ?2"
+	sqlite_create_message_revision_sql         = "INSERT INTO message_revision(message_id, revision, number, message) SELECT id, revision, number, message FROM message WHERE id = ?1"
+	sqlite_update_message_sql                  = "UPDATE message SET message = ?2, number = ?3, revision = revision + 1 WHERE id = ?1 RETURNING revision"
+	sqlite_create_message_recipient_sql        = "INSERT INTO message_recipient(message_id, player_id) VALUES(?1, ?2) ON CONFLICT DO NOTHING"
+	sqlite_select_messages_by_older_then       = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM message WHERE send_time < ?1 AND topic NOT IN (SELECT value FROM json_each(?2)) ORDER BY send_time LIMIT ?3"
+	sqlite_select_messages_by_lobby            = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM message WHERE lobby_id = ?1 ORDER BY number LIMIT ?2"
+	sqlite_select_topic_messages_by_older_then = "SELECT id, send_time, lobby_id, player_id, number, topic, message, direct, channel, team, revision FROM message WHERE topic = ?1 AND send_time < ?2 ORDER BY send_time LIMIT ?3"
+	sqlite_delete_messages_by_older_then       = "DELETE FROM message WHERE send_time < ?1 AND topic NOT IN (SELECT value FROM json_each(?2))"
+	sqlite_delete_messages_by_lobby            = "DELETE FROM message WHERE lobby_id = ?1"
+	sqlite_delete_topic_messages_by_older_then = "DELETE FROM message WHERE topic = ?1 AND send_time < ?2"
+	sqlite_delete_empty_lobby_sequence_sql     = "DELETE FROM lobby_sequence WHERE lobby_id = ?1 AND NOT EXISTS(SELECT 1 FROM message WHERE lobby_id = ?1)"
+	sqlite_select_message_revisions_by_id      = "SELECT message_id, revision, number, message FROM message_revision WHERE message_id IN (SELECT value FROM json_each(?1)) ORDER BY message_id, revision"
+	sqlite_delete_messages_by_id               = "DELETE FROM message WHERE id IN (SELECT value FROM json_each(?1))"
+)
+
+type (
+	// sqliteMessage is the stored form of a message, SQLite has no types for time and json.
+	sqliteMessage struct {
+		ID       uuid.UUID `db:"id"`
+		SendTime int64     `db:"send_time"`
+		LobbyId  uuid.UUID `db:"lobby_id"`
+		PlayerId uuid.UUID `db:"player_id"`
+		Number   int       `db:"number"`
+		Topic    string    `db:"topic"`
+		Message  string    `db:"message"`
+		Direct   bool      `db:"direct"`
+		Channel  string    `db:"channel"`
+		Team     string    `db:"team"`
+		Revision int       `db:"revision"`
+	}
+
+	sqliteMessageRevision struct {
+		MessageId uuid.UUID `db:"message_id"`
+		Revision  int       `db:"revision"`
+		Number    int       `db:"number"`
+		Message   string    `db:"message"`
+	}
+)
+
+func (tx *sqliteTransaction) CreateMessage(message *Message) error {
+	message.Direct = len(message.Recipients) > 0
+	var exists bool
+	if err := tx.tx.QueryRow(sqlite_select_message_exists, message.ID).Scan(&exists); err != nil {
+		return fmt.Errorf("unknown error when checking if message exists: %v", err)
+	}
+	if exists {
+		return ErrMessageAlreadyExists
+	}
+
+	if _, err := tx.tx.Exec(sqlite_next_lobby_number_sql, message.LobbyId); err != nil {
+		return fmt.Errorf("unknown error when increasing number of lobby: %v", err)
+	}
+	var number int
+	if err := tx.tx.QueryRow(sqlite_select_lobby_number_sql, message.LobbyId).Scan(&number); err != nil {
+		return fmt.Errorf("unknown error when getting next number of lobby: %v", err)
+	}
+
+	content, err := json.Marshal(message.Message)
+	if err != nil {
+		return fmt.Errorf("error while marshalling message: %v", err)
+	}
+
+	if _, err := tx.tx.Exec(sqlite_create_message_sql, message.ID, message.SendTime.UnixNano(), message.LobbyId, message.PlayerId, number, message.Topic, string(content), message.Direct, message.Channel, message.Team); err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) {
+			switch sqliteErr.Code() {
+			case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+				return ErrMessageAlreadyExists
+			}
+		}
+
+		return fmt.Errorf("unknown error when inserting message: %v", err)
+	}
+	for _, recipient := range message.Recipients {
+		if _, err := tx.tx.Exec(sqlite_create_message_recipient_sql, message.ID, recipient); err != nil {
+			return fmt.Errorf("unknown error when inserting recipient of message: %v", err)
+		}
+	}
+	message.Number = number
+	return nil
+}
+
+func (tx *sqliteTransaction) GetMessage(lobbyId uuid.UUID, messageId uuid.UUID) (*Message, error) {
+	messages, err := tx.selectMessages(sqlite_select_message_by_id, lobbyId, messageId)
+	if err != nil {
+		return nil, fmt.Errorf("error while selecting message: %v", err)
+	}
+	if len(messages) == 0 {
+		return nil, ErrMessageNotFound
+	}
+	return messages[0], nil
+}
+
+func (tx *sqliteTransaction) EditMessage(message *Message) error {
+	if _, err := tx.tx.Exec(sqlite_next_lobby_number_sql, message.LobbyId); err != nil {
+		return fmt.Errorf("unknown error when increasing number of lobby: %v", err)
+	}
+	var number int
+	if err := tx.tx.QueryRow(sqlite_select_lobby_number_sql, message.LobbyId).Scan(&number); err != nil {
+		return fmt.Errorf("unknown error when getting next number of lobby: %v", err)
+	}
+
+	result, err := tx.tx.Exec(sqlite_create_message_revision_sql, message.ID)
+	if err != nil {
+		return fmt.Errorf("unknown error when inserting revision of message: %v", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unknown error when counting revisions of message: %v", err)
+	}
+	if inserted == 0 {
+		return ErrMessageNotFound
+	}
+
+	content, err := json.Marshal(message.Message)
+	if err != nil {
+		return fmt.Errorf("error while marshalling message: %v", err)
+	}
+	var revision int
+	if err := tx.tx.QueryRow(sqlite_update_message_sql, message.ID, string(content), number).Scan(&revision); err != nil {
+		return fmt.Errorf("unknown error when updating message: %v", err)
+	}
+	message.Number = number
+	message.Revision = revision
+	return nil
+}
+
+func (tx *sqliteTransaction) GetMessages(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, team string, number int) ([]*Message, error) {
+	messages, err := tx.selectMessages(sqlite_select_messages_by_lobby_and_number, lobbyId, toIgnoreplayerId, number, team)
+	if err != nil {
+		return nil, fmt.Errorf("error while selecting all messages: %v", err)
+	}
+	return messages, nil
+}
+
+func (tx *sqliteTransaction) GetMessagesFirstRequest(lobbyId uuid.UUID, toIgnoreplayerId uuid.UUID, team string) ([]*Message, error) {
+	messages, err := tx.selectMessages(sqlite_select_first_messages_of_player, lobbyId, toIgnoreplayerId, team)
+	if err != nil {
+		return nil, fmt.Errorf("error while selecting first messages: %v", err)
+	}
+	return messages, nil
+}
+
+func (tx *sqliteTransaction) GetMessagesOlderThan(time time.Time, keepTopics []string, limit int) ([]*Message, error) {
+	topics, err := marshalTopics(keepTopics)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := tx.selectMessages(sqlite_select_messages_by_older_then, time.UnixNano(), topics, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error while selecting old messages: %v", err)
+	}
+	return messages, nil
+}
+
+func (tx *sqliteTransaction) GetTopicMessagesOlderThan(topic string, time time.Time, limit int) ([]*Message, error) {
+	messages, err := tx.selectMessages(sqlite_select_topic_messages_by_older_then, topic, time.UnixNano(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("error while selecting old messages of topic %s: %v", topic, err)
+	}
+	return messages, nil
+}
+
+func (tx *sqliteTransaction) GetLobbyMessages(lobbyId uuid.UUID, limit int) ([]*Message, error) {
+	messages, err := tx.selectMessages(sqlite_select_messages_by_lobby, lobbyId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error while selecting messages of lobby %v: %v", lobbyId, err)
+	}
+	return messages, nil
+}
+
+func (tx *sqliteTransaction) DeleteMessages(time time.Time, keepTopics []string) (int64, error) {
+	topics, err := marshalTopics(keepTopics)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := tx.deleteMessages(sqlite_delete_messages_by_older_then, time.UnixNano(), topics)
+	if err != nil {
+		return 0, fmt.Errorf("unknown error when deliting messages: %v", err)
+	}
+	return deleted, nil
+}
+
+func (tx *sqliteTransaction) DeleteTopicMessages(topic string, time time.Time) (int64, error) {
+	deleted, err := tx.deleteMessages(sqlite_delete_topic_messages_by_older_then, topic, time.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("unknown error when deliting messages of topic %s: %v", topic, err)
+	}
+	return deleted, nil
+}
+
+func (tx *sqliteTransaction) DeleteLobbyMessages(lobbyId uuid.UUID) (int64, error) {
+	deleted, err := tx.deleteMessages(sqlite_delete_messages_by_lobby, lobbyId)
+	if err != nil {
+		return 0, fmt.Errorf("unknown error when deliting messages of lobby %v: %v", lobbyId, err)
+	}
+	return deleted, nil
+}
+
+func (tx *sqliteTransaction) DeleteLobbySequence(lobbyId uuid.UUID) error {
+	if _, err := tx.tx.Exec(sqlite_delete_empty_lobby_sequence_sql, lobbyId); err != nil {
+		return fmt.Errorf("unknown error when deleting sequence of lobby %v: %v", lobbyId, err)
+	}
+	return nil
+}
+
+func (tx *sqliteTransaction) DeleteMessagesById(messageIds []uuid.UUID) (int64, error) {
+	ids, err := json.Marshal(messageIds)
+	if err != nil {
+		return 0, fmt.Errorf("error while marshalling message ids: %v", err)
+	}
+	deleted, err := tx.deleteMessages(sqlite_delete_messages_by_id, string(ids))
+	if err != nil {
+		return 0, fmt.Errorf("unknown error when deliting messages by id: %v", err)
+	}
+	return deleted, nil
+}
+
+func (tx *sqliteTransaction) GetMessageRevisions(messageIds []uuid.UUID) ([]*MessageRevision, error) {
+	ids, err := json.Marshal(messageIds)
+	if err != nil {
+		return nil, fmt.Errorf("error while marshalling message ids: %v", err)
+	}
+	var sqliteRevisions []*sqliteMessageRevision
+	if err := sqlscan.Select(context.Background(), tx.tx, &sqliteRevisions, sqlite_select_message_revisions_by_id, string(ids)); err != nil {
+		return nil, fmt.Errorf("error while selecting revisions of messages: %v", err)
+	}
+	revisions := make([]*MessageRevision, len(sqliteRevisions))
+	for index, sqliteRevision := range sqliteRevisions {
+		var content map[string]interface{}
+		if err := json.Unmarshal([]byte(sqliteRevision.Message), &content); err != nil {
+			return nil, fmt.Errorf("error while unmarshalling revision %d of message %v: %v", sqliteRevision.Revision, sqliteRevision.MessageId, err)
+		}
+		revisions[index] = &MessageRevision{MessageId: sqliteRevision.MessageId, Revision: sqliteRevision.Revision, Number: sqliteRevision.Number, Message: content}
+	}
+	return revisions, nil
+}
+
+func (tx *sqliteTransaction) deleteMessages(query string, args ...interface{}) (int64, error) {
+	result, err := tx.tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func marshalTopics(topics []string) (string, error) {
+	if topics == nil {
+		topics = []string{}
+	}
+	content, err := json.Marshal(topics)
+	if err != nil {
+		return "", fmt.Errorf("error while marshalling topics: %v", err)
+	}
+	return string(content), nil
+}
+
+func (tx *sqliteTransaction) selectMessages(query string, args ...interface{}) ([]*Message, error) {
+	var sqliteMessages []*sqliteMessage
+	if err := sqlscan.Select(context.Background(), tx.tx, &sqliteMessages, query, args...); err != nil {
+		return nil, err
+	}
+	return mapSqliteMessages(sqliteMessages)
+}
+
+func mapSqliteMessages(sqliteMessages []*sqliteMessage) ([]*Message, error) {
+	messages := make([]*Message, len(sqliteMessages))
+	for index, sqliteMessage := range sqliteMessages {
+		var content map[string]interface{}
+		if err := json.Unmarshal([]byte(sqliteMessage.Message), &content); err != nil {
+			return nil, fmt.Errorf("error while unmarshalling message %v: %v", sqliteMessage.ID, err)
+		}
+		messages[index] = &Message{ID: sqliteMessage.ID, SendTime: time.Unix(0, sqliteMessage.SendTime), LobbyId: sqliteMessage.LobbyId, PlayerId: sqliteMessage.PlayerId, Number: sqliteMessage.Number, Topic: sqliteMessage.Topic, Message: content, Direct: sqliteMessage.Direct, Channel: sqliteMessage.Channel, Team: sqliteMessage.Team, Revision: sqliteMessage.Revision}
+	}
+	return messages, nil
+}
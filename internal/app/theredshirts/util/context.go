@@ -1,10 +1,22 @@
 package util
 
 import (
+	"context"
+
 	log "github.com/sirupsen/logrus"
 )
 
 type Context struct {
 	CorrelationId string
 	Logger        *log.Entry
+	// Request is the context of the http request, it is nil outside of requests
+	Request context.Context
+}
+
+// Done is closed when the client of the request is gone. Outside of requests it is never closed.
+func (context *Context) Done() <-chan struct{} {
+	if context.Request == nil {
+		return nil
+	}
+	return context.Request.Done()
 }
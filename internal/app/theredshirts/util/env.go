@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -29,6 +30,52 @@ func GetEnvIntWithFallback(key string, fallback int) (int, error) {
 	return fallback, nil
 }
 
+func GetEnvBoolWithFallback(key string, fallback bool) (bool, error) {
+	if value, ok := os.LookupEnv(key); ok {
+		return strconv.ParseBool(value)
+	}
+	return fallback, nil
+}
+
+// GetEnvListWithFallback parses a list like "FIRST,SECOND", empty entries are skipped.
+func GetEnvListWithFallback(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	result := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// GetEnvIntMapWithFallback parses a list like "KEY=1,OTHER_KEY=2".
+func GetEnvIntMapWithFallback(key string, fallback map[string]int) (map[string]int, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback, nil
+	}
+	result := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		entryKey, entryValue, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("entry %s of environment variable %s has no value", entry, key)
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(entryValue))
+		if err != nil {
+			return nil, err
+		}
+		result[strings.TrimSpace(entryKey)] = number
+	}
+	return result, nil
+}
+
 func GetEnvUUID(key string) (uuid.UUID, error) {
 	if value, ok := os.LookupEnv(key); ok {
 		return uuid.Parse(value)
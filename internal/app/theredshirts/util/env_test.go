@@ -73,3 +73,83 @@ func TestGetEnvIntWithFallback_WrongFormat(t *testing.T) {
 	assert.Equal(t, 0, value)
 	assert.ErrorContains(t, err, "invalid syntax")
 }
+
+func TestGetEnvBoolWithFallback_Successfully(t *testing.T) {
+	someEnv := "SOME_ENV"
+	t.Setenv(someEnv, "true")
+
+	value, err := GetEnvBoolWithFallback(someEnv, false)
+	assert.Nil(t, err)
+	assert.True(t, value)
+}
+
+func TestGetEnvBoolWithFallback_WrongFormat(t *testing.T) {
+	someEnv := "SOME_ENV"
+	t.Setenv(someEnv, "yes please")
+
+	_, err := GetEnvBoolWithFallback(someEnv, false)
+	assert.ErrorContains(t, err, "invalid syntax")
+}
+
+func TestGetEnvListWithFallback_Successfully(t *testing.T) {
+	someEnv := "SOME_ENV"
+	t.Setenv(someEnv, "FIRST, SECOND,,")
+
+	value := GetEnvListWithFallback(someEnv, []string{"FALLBACK"})
+	assert.Equal(t, []string{"FIRST", "SECOND"}, value)
+}
+
+func TestGetEnvListWithFallback_NotFound(t *testing.T) {
+	value := GetEnvListWithFallback("SOME_ENV", []string{"FALLBACK"})
+	assert.Equal(t, []string{"FALLBACK"}, value)
+}
+
+func TestGetEnvIntMapWithFallback_Successfully(t *testing.T) {
+	someEnv := "SOME_ENV"
+	someEnvValue := "SOME_KEY=5, OTHER_KEY=-1"
+	someFallbackValue := map[string]int{"FALLBACK_KEY": 10}
+	t.Setenv(someEnv, someEnvValue)
+
+	value, err := GetEnvIntMapWithFallback(someEnv, someFallbackValue)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"SOME_KEY": 5, "OTHER_KEY": -1}, value)
+}
+
+func TestGetEnvIntMapWithFallback_Empty(t *testing.T) {
+	someEnv := "SOME_ENV"
+	someFallbackValue := map[string]int{"FALLBACK_KEY": 10}
+	t.Setenv(someEnv, "")
+
+	value, err := GetEnvIntMapWithFallback(someEnv, someFallbackValue)
+	assert.Nil(t, err)
+	assert.Empty(t, value)
+}
+
+func TestGetEnvIntMapWithFallback_NotFound(t *testing.T) {
+	someEnv := "SOME_ENV"
+	someFallbackValue := map[string]int{"FALLBACK_KEY": 10}
+
+	value, err := GetEnvIntMapWithFallback(someEnv, someFallbackValue)
+	assert.Nil(t, err)
+	assert.Equal(t, someFallbackValue, value)
+}
+
+func TestGetEnvIntMapWithFallback_MissingValue(t *testing.T) {
+	someEnv := "SOME_ENV"
+	someEnvValue := "SOME_KEY"
+	t.Setenv(someEnv, someEnvValue)
+
+	value, err := GetEnvIntMapWithFallback(someEnv, nil)
+	assert.Nil(t, value)
+	assert.ErrorContains(t, err, "has no value")
+}
+
+func TestGetEnvIntMapWithFallback_WrongFormat(t *testing.T) {
+	someEnv := "SOME_ENV"
+	someEnvValue := "SOME_KEY=five"
+	t.Setenv(someEnv, someEnvValue)
+
+	value, err := GetEnvIntMapWithFallback(someEnv, nil)
+	assert.Nil(t, value)
+	assert.ErrorContains(t, err, "invalid syntax")
+}